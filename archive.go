@@ -7,6 +7,7 @@ package main
 import (
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 )
 
@@ -18,7 +19,7 @@ func GetEntriesByDate(entries []*Entry) EntriesByDate {
 		es = append(es, e)
 	}
 
-	sort.Sort(es)
+	sort.Stable(es)
 
 	return es
 }
@@ -33,8 +34,14 @@ func (e EntriesByDate) Len() int {
 }
 
 // Less returns true if the value at i is less than the value at j.
+// Entries created at the same time are ordered by their name so the
+// order is always the same.
 func (e EntriesByDate) Less(i, j int) bool {
-	return e[i].Created.After(e[j].Created)
+	if !e[i].Created.Equal(e[j].Created) {
+		return e[i].Created.After(e[j].Created)
+	}
+
+	return e[i].Name < e[j].Name
 }
 
 // Swap switches the elemens at i and j.
@@ -42,35 +49,166 @@ func (e EntriesByDate) Swap(i, j int) {
 	e[i], e[j] = e[j], e[i]
 }
 
+// SortEntries returns a copy of the given entries sorted by the given
+// order. It can be created (newest first), updated (most recently
+// updated first), title or weight (see EntriesByWeight). An empty order
+// is the same as created.
+func SortEntries(entries []*Entry, by string) ([]*Entry, error) {
+	es := make([]*Entry, len(entries))
+	copy(es, entries)
+
+	switch by {
+	case "", "created":
+		sort.Stable(EntriesByDate(es))
+	case "updated":
+		sort.Stable(EntriesByUpdated(es))
+	case "title":
+		sort.Stable(EntriesByTitle(es))
+	case "weight":
+		sort.Stable(EntriesByWeight(es))
+	default:
+		return nil, fmt.Errorf("unknown sort order: %s", by)
+	}
+
+	return es, nil
+}
+
+// PinEntries moves the pinned entries to the front of the given
+// entries. Otherwise, the order of the entries is kept.
+func PinEntries(entries []*Entry) []*Entry {
+	es := make([]*Entry, 0, len(entries))
+	for _, e := range entries {
+		if e.Pinned {
+			es = append(es, e)
+		}
+	}
+	for _, e := range entries {
+		if !e.Pinned {
+			es = append(es, e)
+		}
+	}
+
+	return es
+}
+
+// EntriesByUpdated is a slice of entries which is sortable using go's
+// sort package by the date they were updated, most recent being
+// first.
+type EntriesByUpdated []*Entry
+
+// Len returns the length of the EntriesByUpdated.
+func (e EntriesByUpdated) Len() int {
+	return len(e)
+}
+
+// Less returns true if the value at i is less than the value at j.
+func (e EntriesByUpdated) Less(i, j int) bool {
+	if !e[i].Updated.Equal(e[j].Updated) {
+		return e[i].Updated.After(e[j].Updated)
+	}
+
+	return e[i].Name < e[j].Name
+}
+
+// Swap switches the elemens at i and j.
+func (e EntriesByUpdated) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
+
+// EntriesByTitle is a slice of entries which is sortable using go's
+// sort package by their title regardless of case.
+type EntriesByTitle []*Entry
+
+// Len returns the length of the EntriesByTitle.
+func (e EntriesByTitle) Len() int {
+	return len(e)
+}
+
+// Less returns true if the value at i is less than the value at j.
+func (e EntriesByTitle) Less(i, j int) bool {
+	ti, tj := strings.ToLower(e[i].Title), strings.ToLower(e[j].Title)
+	if ti != tj {
+		return ti < tj
+	}
+
+	return e[i].Name < e[j].Name
+}
+
+// Swap switches the elemens at i and j.
+func (e EntriesByTitle) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
+
+// EntriesByWeight is a slice of entries which is sortable using go's
+// sort package by their Weight, lightest first. Entries without a
+// Weight come after the ones with one. Entries with the same Weight are
+// sorted by date, newest first.
+type EntriesByWeight []*Entry
+
+// Len returns the length of the EntriesByWeight.
+func (e EntriesByWeight) Len() int {
+	return len(e)
+}
+
+// Less returns true if the value at i is less than the value at j.
+func (e EntriesByWeight) Less(i, j int) bool {
+	wi, wj := e[i].Weight, e[j].Weight
+	if wi != wj {
+		if wi == 0 || wj == 0 {
+			return wj == 0
+		}
+		return wi < wj
+	}
+
+	return EntriesByDate(e).Less(i, j)
+}
+
+// Swap switches the elemens at i and j.
+func (e EntriesByWeight) Swap(i, j int) {
+	e[i], e[j] = e[j], e[i]
+}
+
 // GetArchives formats the given entries sorted by year in a slice of
-// YearEntries suitable for making the archvie page.
+// YearEntries suitable for making the archvie page. The names of the
+// months use the MonthFormat of the entries' Locale and their titles
+// use its MonthYearFormat.
 func GetArchives(es EntriesByDate) []*YearEntries {
 	yes := []*YearEntries{}
 	curYear := -1
 	var curMonth time.Month = -1
 
 	for _, e := range es {
+		l := GetLocale(e.Language)
+
 		// Create the Year and Month as necessary.
-		if e.Created.Year() != curYear {
+		created := e.Created.In(Location)
+		if created.Year() != curYear {
 			yes = append(yes, &YearEntries{
-				Year:   fmt.Sprintf("%v", e.Created.Year()),
+				Year:   fmt.Sprintf("%v", created.Year()),
 				Months: []*MonthEntries{},
 			})
-			curYear = e.Created.Year()
+			curYear = created.Year()
 			curMonth = -1
 		}
-		if e.Created.Month() != curMonth {
+		if created.Month() != curMonth {
 			yes[len(yes)-1].Months = append(yes[len(yes)-1].Months,
 				&MonthEntries{
-					Month:   e.Created.Month().String(),
+					Month:   l.Format(created, l.MonthFormat),
+					Year:    yes[len(yes)-1].Year,
+					Number:  created.Format("01"),
+					Title:   l.Format(created, l.MonthYearFormat),
 					Entries: []*Entry{},
 				})
-			curMonth = e.Created.Month()
+			curMonth = created.Month()
 		}
 
 		// Append this entry to the last month in the last year.
 		yes[len(yes)-1].Months[len(yes[len(yes)-1].Months)-1].Entries =
 			append(yes[len(yes)-1].Months[len(yes[len(yes)-1].Months)-1].Entries, e)
+
+		// Keep a running total for the year.
+		yes[len(yes)-1].WordCount += e.WordCount
+		yes[len(yes)-1].ReadingTime = ReadingTime(yes[len(yes)-1].WordCount)
 	}
 
 	return yes
@@ -80,13 +218,36 @@ func GetArchives(es EntriesByDate) []*YearEntries {
 // implements the sort interface for sorting the entries by date
 // descending.
 type MonthEntries struct {
-	// The name of the month.
+	// The name of the month (e.g. January).
 	Month string
 
+	// The year the month is in (e.g. 2013).
+	Year string
+
+	// The number of the month with a leading zero (e.g. 07).
+	Number string
+
+	// The name of the month and its year (e.g. January 2013).
+	Title string
+
 	// A list of blog entries for this month.
 	Entries []*Entry
 }
 
+// Url returns the url of the page for this month relative to the root
+// of the site in the page's language.
+func (m *MonthEntries) Url() string {
+	return "archive/" + m.Year + "/" + m.Number + "/index.html"
+}
+
+// languages returns the languages that have entries in this month.
+func (m *MonthEntries) languages() []string {
+	return versionLanguages(m.Entries, func(e *Entry) bool {
+		return e.Created.In(Location).Format("2006/01") ==
+			m.Year+"/"+m.Number
+	})
+}
+
 // YearEntries is a list of entries and their associated year. It
 // implements the sort interface for sorting the MonthEntries by date
 // descending.
@@ -96,4 +257,29 @@ type YearEntries struct {
 
 	// A list of MonthEntries for this year.
 	Months []*MonthEntries
+
+	// The total number of words in the entries for this year.
+	WordCount int
+
+	// The total number of minutes it takes to read the entries for
+	// this year.
+	ReadingTime int
+}
+
+// Url returns the url of the page for this year relative to the root
+// of the site in the page's language.
+func (y *YearEntries) Url() string {
+	return "archive/" + y.Year + "/index.html"
+}
+
+// languages returns the languages that have entries in this year.
+func (y *YearEntries) languages() []string {
+	entries := []*Entry{}
+	for _, m := range y.Months {
+		entries = append(entries, m.Entries...)
+	}
+
+	return versionLanguages(entries, func(e *Entry) bool {
+		return e.Created.In(Location).Format("2006") == y.Year
+	})
 }
@@ -0,0 +1,140 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"github.com/tdewolff/minify/v2"
+	"github.com/tdewolff/minify/v2/css"
+	minhtml "github.com/tdewolff/minify/v2/html"
+	"github.com/tdewolff/minify/v2/js"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// Assets maps the url of each fingerprinted static asset relative to
+// the site (e.g. css/site.css) to the url of its fingerprinted copy
+// (e.g. css/site.0123abcd.css).
+var Assets = AssetSet{}
+
+// assetTypes are the media types of the assets that are minified and
+// fingerprinted keyed by their extension.
+var assetTypes = map[string]string{
+	".css": "text/css",
+	".js":  "application/javascript",
+}
+
+// minifier is used to minify CSS, JavaScript and HTML.
+var minifier = newMinifier()
+
+// newMinifier creates the minifier for all of the types we know about.
+func newMinifier() *minify.M {
+	m := minify.New()
+	m.AddFunc("text/css", css.Minify)
+	m.AddFunc("application/javascript", js.Minify)
+	m.Add("text/html", &minhtml.Minifier{
+		KeepDocumentTags:    true,
+		KeepEndTags:         true,
+		KeepQuotes:          true,
+		KeepDefaultAttrVals: true,
+	})
+
+	return m
+}
+
+// AssetSet is a map of asset urls to their fingerprinted urls.
+type AssetSet map[string]string
+
+// Process looks through src recursively for CSS and JavaScript files.
+// Each one is written to the same place in dest and along with a copy
+// that has the first eight characters of the SHA1 of its contents in
+// its name. If Minify is true, they are minified first. The given
+// prefix is prepended to the url of each asset.
+func (as AssetSet) Process(dest, src, prefix string) error {
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		s := path.Join(src, file.Name())
+		d := path.Join(dest, file.Name())
+
+		if file.IsDir() {
+			err = as.Process(d, s, prefix+file.Name()+"/")
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		ext := path.Ext(file.Name())
+		mediatype, ok := assetTypes[ext]
+		if !ok {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(s)
+		if err != nil {
+			return err
+		}
+
+		if Minify {
+			contents, err = minifier.Bytes(mediatype, contents)
+			if err != nil {
+				return err
+			}
+
+			err = ioutil.WriteFile(d, contents, 0644)
+			if err != nil {
+				return err
+			}
+		}
+
+		// Save the fingerprinted copy.
+		sum := sha1.Sum(contents)
+		name := strings.TrimSuffix(file.Name(), ext) + "." +
+			hex.EncodeToString(sum[:])[:8] + ext
+
+		err = ioutil.WriteFile(path.Join(dest, name), contents, 0644)
+		if err != nil {
+			return err
+		}
+
+		as[prefix+file.Name()] = prefix + name
+	}
+
+	return nil
+}
+
+// Asset returns the url of the fingerprinted copy of the static asset
+// with the given url. If there isn't one, the url is returned as is.
+// It's available in all templates as the asset function:
+//
+//      <link rel="stylesheet" href="{{asset "css/site.css"}}">
+func Asset(url string) string {
+	lead := ""
+	if strings.HasPrefix(url, "/") {
+		lead = "/"
+	}
+
+	if fp, ok := Assets[path.Clean(strings.TrimPrefix(url, "/"))]; ok {
+		return lead + fp
+	}
+
+	return url
+}
+
+// MinifyHTML minifies the given HTML page if Minify is true. Otherwise,
+// it's returned unchanged.
+func MinifyHTML(page []byte) ([]byte, error) {
+	if !Minify {
+		return page, nil
+	}
+
+	return minifier.Bytes("text/html", page)
+}
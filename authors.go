@@ -0,0 +1,209 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"os"
+	"sort"
+	"strings"
+)
+
+// AuthorProfiles are the authors from the AuthorsFile keyed by their
+// ID.
+var AuthorProfiles = map[string]*Author{}
+
+// Author is the profile of a person who writes blog entries. Profiles
+// are loaded from the AuthorsFile, which is a TOML file with a table
+// for each author keyed by their ID:
+//
+//      [jmarsh]
+//      name = "Joshua Marsh"
+//      bio = "I like to write code."
+//      avatar = "images/jmarsh.png"
+//
+//      [jmarsh.links]
+//      GitHub = "https://github.com/icub3d"
+type Author struct {
+	// ID is the key of the author in the AuthorsFile. It's also used
+	// for the name of the author's pages.
+	ID string
+
+	// Name is the name of the author.
+	Name string `toml:"name"`
+
+	// Bio is a little bit about the author.
+	Bio string `toml:"bio"`
+
+	// Avatar is the url of a picture of the author relative to the root
+	// of the site.
+	Avatar string `toml:"avatar"`
+
+	// Links are the author's links to elsewhere keyed by their name.
+	Links map[string]string `toml:"links"`
+}
+
+// Url returns the url of the author's page relative to the root of the
+// site in the page's language.
+func (a *Author) Url() string {
+	return "authors/" + a.ID + ".html"
+}
+
+// FeedUrl returns the url of the author's RSS feed relative to the
+// root of the site in the page's language.
+func (a *Author) FeedUrl() string {
+	return "authors/" + a.ID + ".rss"
+}
+
+// LoadAuthors reads the author profiles from the given TOML file. It's
+// fine if the file doesn't exist.
+func LoadAuthors(file string) (map[string]*Author, error) {
+	authors := map[string]*Author{}
+
+	_, err := toml.DecodeFile(file, &authors)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return authors, nil
+		}
+		return nil, err
+	}
+
+	for id, a := range authors {
+		a.ID = id
+		if a.Name == "" {
+			return nil, fmt.Errorf("author %s has no name", id)
+		}
+	}
+
+	return authors, nil
+}
+
+// ResolveAuthors finds the profiles of the given comma separated list
+// of authors. Each one can be either the ID or the name of an author
+// in the AuthorProfiles. Authors without a profile get one with just
+// their name.
+func ResolveAuthors(list string) ([]*Author, error) {
+	authors := []*Author{}
+
+	for _, name := range strings.Split(list, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		a, ok := AuthorProfiles[name]
+		if !ok {
+			a, ok = findAuthorByName(name)
+		}
+		if !ok {
+			id, err := MakeBlogName(strings.ToLower(name))
+			if err != nil {
+				return nil, err
+			}
+			a = &Author{ID: id, Name: name}
+		}
+
+		authors = append(authors, a)
+	}
+
+	return authors, nil
+}
+
+// findAuthorByName is a helper function for ResolveAuthors that looks
+// for a profile with the given name.
+func findAuthorByName(name string) (*Author, bool) {
+	for _, a := range AuthorProfiles {
+		if strings.EqualFold(a.Name, name) {
+			return a, true
+		}
+	}
+
+	return nil, false
+}
+
+// AuthorEntries is an author and the entries they wrote. It is used
+// for making the author pages.
+type AuthorEntries struct {
+	*Author
+
+	// The list of Entries the author wrote, newest first.
+	Entries EntriesByDate
+}
+
+// languages returns the languages the author has written entries in.
+func (ae *AuthorEntries) languages() []string {
+	return versionLanguages(ae.Entries, func(e *Entry) bool {
+		for _, a := range e.Authors {
+			if a.ID == ae.ID {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// Authors is a map of AuthorEntries keyed by the author's ID.
+type Authors map[string]*AuthorEntries
+
+// GetAuthors builds Authors from the given list of blogs.
+func GetAuthors(entries []*Entry) Authors {
+	as := make(Authors)
+
+	for _, blog := range entries {
+		for _, a := range blog.Authors {
+			ae, ok := as[a.ID]
+			if !ok {
+				ae = &AuthorEntries{Author: a, Entries: EntriesByDate{}}
+				as[a.ID] = ae
+			}
+
+			ae.Entries = append(ae.Entries, blog)
+		}
+	}
+
+	for _, ae := range as {
+		sort.Stable(ae.Entries)
+	}
+
+	return as
+}
+
+// Slice returns the Authors as a slice. The list is sorted by the
+// authors' names.
+func (as Authors) Slice() AuthorSlice {
+	s := make(AuthorSlice, 0, len(as))
+	for _, ae := range as {
+		s = append(s, ae)
+	}
+
+	sort.Sort(s)
+
+	return s
+}
+
+// AuthorSlice is a slice of AuthorEntries that is returned by the
+// Slice() function for Authors. It implements the sorting interface
+// for go's sort package.
+type AuthorSlice []*AuthorEntries
+
+// Len returns the length of the AuthorSlice.
+func (a AuthorSlice) Len() int {
+	return len(a)
+}
+
+// Less returns true if the value at i is less than the value at j.
+func (a AuthorSlice) Less(i, j int) bool {
+	if a[i].Name != a[j].Name {
+		return a[i].Name < a[j].Name
+	}
+
+	return a[i].ID < a[j].ID
+}
+
+// Swap switches the elemens at i and j.
+func (a AuthorSlice) Swap(i, j int) {
+	a[i], a[j] = a[j], a[i]
+}
@@ -0,0 +1,153 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// linkRegex matches the src and href attributes within HTML.
+var linkRegex = regexp.MustCompile(`(src|href)="([^"]*)"`)
+
+// CopyAssets copies the assets of a page bundle into a directory named
+// after the entry inside of the given directory. They are shared by
+// all of the entry's Translations. Images are processed
+// like the ones in the StaticDir. Entries that aren't page bundles are
+// ignored.
+func (e *Entry) CopyAssets(dir string) error {
+	if e.Bundle == "" {
+		return nil
+	}
+
+	dest := path.Join(dir, e.assetDir())
+	err := MakeDirIfNotExists(dest)
+	if err != nil {
+		return err
+	}
+
+	files, err := ioutil.ReadDir(e.Bundle)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		s := path.Join(e.Bundle, file.Name())
+		d := path.Join(dest, file.Name())
+
+		// The entry and its translations aren't assets.
+		if isBundleIndex(file.Name()) {
+			continue
+		}
+
+		if file.IsDir() {
+			err = MakeDirIfNotExists(d)
+			if err != nil {
+				return err
+			}
+
+			err = CopyFilesRecursively(d, s)
+		} else {
+			err = CopyFile(d, s)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return Images.Process(dest, e.Bundle, e.assetDir()+"/")
+}
+
+// assetDir returns the directory the assets of this page bundle are
+// copied to relative to the OutputDir. They are shared by all
+// languages.
+func (e *Entry) assetDir() string {
+	return path.Join(path.Dir(e.Url), e.Name)
+}
+
+// rewriteBundleLinks changes the relative links in the given HTML that
+// refer to the assets of this page bundle so that they point to where
+// CopyAssets puts them.
+func (e *Entry) rewriteBundleLinks(content string) string {
+	if e.Bundle == "" {
+		return content
+	}
+
+	return linkRegex.ReplaceAllStringFunc(content, func(attr string) string {
+		m := linkRegex.FindStringSubmatch(attr)
+		link := m[2]
+
+		if !isRelativeLink(link) {
+			return attr
+		}
+
+		// Only rewrite links to files that are actually in the bundle.
+		file := link
+		if i := strings.IndexAny(file, "?#"); i >= 0 {
+			file = file[:i]
+		}
+		if _, err := os.Stat(path.Join(e.Bundle, file)); err != nil {
+			return attr
+		}
+
+		// The assets are shared by all languages, so we go back to the
+		// root of the site to get to them.
+		root := ""
+		if dir := e.pageDir(); dir != "." {
+			root = strings.Repeat("../", strings.Count(dir, "/")+1)
+		}
+
+		return m[1] + `="` + root + path.Join(e.assetDir(), link) + `"`
+	})
+}
+
+// bundleIndexes returns the paths of the index.md files in the given
+// directory. There is one for each language the bundle is written in.
+// If there aren't any, the directory isn't a page bundle.
+func bundleIndexes(dir string) ([]string, error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := []string{}
+	for _, file := range files {
+		if !file.IsDir() && isBundleIndex(file.Name()) {
+			indexes = append(indexes, path.Join(dir, file.Name()))
+		}
+	}
+
+	return indexes, nil
+}
+
+// isBundleIndex returns true if the given file name is the entry of a
+// page bundle (index.md or index.<language>.md).
+func isBundleIndex(name string) bool {
+	if path.Ext(name) != ".md" {
+		return false
+	}
+
+	base, _ := splitLanguage(name)
+	return base == "index"
+}
+
+// isRelativeLink returns true if the given link is relative to the
+// current page. Absolute paths, fragments and links with a scheme are
+// not.
+func isRelativeLink(link string) bool {
+	if link == "" || strings.HasPrefix(link, "/") ||
+		strings.HasPrefix(link, "#") {
+		return false
+	}
+
+	// Look for a scheme like http: or mailto: before the first slash.
+	colon := strings.Index(link, ":")
+	slash := strings.IndexAny(link, "/?#")
+
+	return colon < 0 || (slash >= 0 && slash < colon)
+}
@@ -0,0 +1,318 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"golang.org/x/image/draw"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// The size of the social cards in pixels. It's what most social
+// networks show for large previews.
+const (
+	cardWidth  = 1200
+	cardHeight = 630
+	cardMargin = 80
+)
+
+// CardBackground is the background of the social cards. It's either a
+// color (e.g. #1d2731) or the path of an image in the StaticDir.
+var CardBackground string
+
+// CardUrl returns the url of the entry's social card relative to the
+// directory of its language. It's next to the entry's page.
+func (e *Entry) CardUrl() string {
+	return path.Join(path.Dir(e.Url), e.Name+"-card.png")
+}
+
+// MakeCard creates the social card of the given entry in the given
+// directory. It's a PNG image with the title, date and authors of the
+// entry and the name of the site on the CardBackground. Cards are kept
+// in the CacheDir so they are only drawn again when they change.
+func MakeCard(dir string, e *Entry) error {
+	authors := e.Author
+	if len(e.Authors) > 0 {
+		names := []string{}
+		for _, a := range e.Authors {
+			names = append(names, a.Name)
+		}
+		authors = strings.Join(names, ", ")
+	}
+
+	byline := e.CDate()
+	if authors != "" {
+		if byline != "" {
+			byline += " · "
+		}
+		byline += authors
+	}
+
+	// Everything on the card is part of its name in the cache.
+	bg, err := cardBackground()
+	if err != nil {
+		return err
+	}
+	sum := sha1.Sum([]byte(strings.Join([]string{e.Title, byline,
+		Site.Title, CardBackground, bg.key}, "\x00")))
+	key := hex.EncodeToString(sum[:])
+
+	return cachedImage(path.Join(dir, e.CardUrl()), "card-"+key+".png",
+		func() (image.Image, error) {
+			return drawCard(e.Title, byline, Site.Title, bg)
+		})
+}
+
+// cardBack is the decoded CardBackground.
+type cardBack struct {
+	// color is the color of the background if it's not an image.
+	color color.Color
+
+	// image is the background image or nil.
+	image image.Image
+
+	// key identifies the background image in the cache.
+	key string
+}
+
+// cardBackgrounds are the backgrounds that have been decoded so far
+// keyed by the CardBackground.
+var cardBackgrounds = map[string]*cardBack{}
+
+// cardBackground decodes the CardBackground.
+func cardBackground() (*cardBack, error) {
+	if bg, ok := cardBackgrounds[CardBackground]; ok {
+		return bg, nil
+	}
+
+	bg := &cardBack{}
+	if c, ok := parseColor(CardBackground); ok {
+		bg.color = c
+	} else {
+		file := path.Join(StaticDir, CardBackground)
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("card background: %v", err)
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(contents))
+		if err != nil {
+			return nil, fmt.Errorf("card background %s: %v", file, err)
+		}
+
+		sum := sha1.Sum(contents)
+		bg.image = orient(img, exifOrientation(contents))
+		bg.key = hex.EncodeToString(sum[:])
+	}
+
+	cardBackgrounds[CardBackground] = bg
+	return bg, nil
+}
+
+// parseColor parses a color like #1d2731 or #123. It returns false if
+// the string isn't one.
+func parseColor(s string) (color.Color, bool) {
+	if !strings.HasPrefix(s, "#") {
+		return nil, false
+	}
+
+	hex := s[1:]
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return nil, false
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return nil, false
+	}
+
+	return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}, true
+}
+
+// cardFaces are the font faces used on the cards keyed by their size,
+// which is negative for the bold ones.
+var cardFaces = map[float64]font.Face{}
+
+// cardFace returns the face of the Go font with the given size.
+func cardFace(size float64, bold bool) (font.Face, error) {
+	key := size
+	ttf := goregular.TTF
+	if bold {
+		key, ttf = -size, gobold.TTF
+	}
+
+	if f, ok := cardFaces[key]; ok {
+		return f, nil
+	}
+
+	fnt, err := opentype.Parse(ttf)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := opentype.NewFace(fnt, &opentype.FaceOptions{
+		Size:    size,
+		DPI:     72,
+		Hinting: font.HintingFull,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cardFaces[key] = f
+	return f, nil
+}
+
+// drawCard draws a social card.
+func drawCard(title, byline, site string, bg *cardBack) (image.Image,
+	error) {
+
+	img := image.NewRGBA(image.Rect(0, 0, cardWidth, cardHeight))
+	fg := color.Color(color.White)
+
+	if bg.image != nil {
+		// Cover the card with the image and darken it so the text can
+		// be read.
+		img = cover(bg.image, cardWidth, cardHeight)
+		draw.Draw(img, img.Bounds(),
+			image.NewUniform(color.RGBA{0, 0, 0, 140}), image.Point{},
+			draw.Over)
+	} else {
+		draw.Draw(img, img.Bounds(), image.NewUniform(bg.color),
+			image.Point{}, draw.Src)
+
+		// Dark text on light backgrounds.
+		r, g, b, _ := bg.color.RGBA()
+		if 299*r+587*g+114*b > 1000*0x8000 {
+			fg = color.RGBA{0x22, 0x22, 0x22, 0xff}
+		}
+	}
+
+	width := cardWidth - 2*cardMargin
+
+	// Use the biggest title that fits in four lines.
+	var lines []string
+	var face font.Face
+	for _, size := range []float64{76, 64, 54, 46} {
+		f, err := cardFace(size, true)
+		if err != nil {
+			return nil, err
+		}
+
+		face, lines = f, wrapText(f, title, width)
+		if len(lines) <= 4 {
+			break
+		}
+	}
+	if len(lines) > 4 {
+		lines = lines[:4]
+		lines[3] = ellipsize(face, lines[3], width)
+	}
+
+	d := &font.Drawer{Dst: img, Src: image.NewUniform(fg), Face: face}
+	height := face.Metrics().Height.Ceil() * 12 / 10
+	y := cardMargin + face.Metrics().Ascent.Ceil()
+	for _, line := range lines {
+		d.Dot = fixed.P(cardMargin, y)
+		d.DrawString(line)
+		y += height
+	}
+
+	// The byline is under the title and the name of the site is at the
+	// bottom.
+	small, err := cardFace(32, false)
+	if err != nil {
+		return nil, err
+	}
+	d.Face = small
+
+	if byline != "" {
+		if font.MeasureString(small, byline).Ceil() > width {
+			byline = ellipsize(small, byline, width)
+		}
+		d.Dot = fixed.P(cardMargin, y+small.Metrics().Height.Ceil()/2)
+		d.DrawString(byline)
+	}
+
+	if site != "" {
+		d.Face, err = cardFace(36, true)
+		if err != nil {
+			return nil, err
+		}
+		if font.MeasureString(d.Face, site).Ceil() > width {
+			site = ellipsize(d.Face, site, width)
+		}
+		d.Dot = fixed.P(cardMargin, cardHeight-cardMargin)
+		d.DrawString(site)
+	}
+
+	return img, nil
+}
+
+// wrapText splits the given text into lines that fit in the given
+// width when drawn with the given face. Words that don't fit on a line
+// of their own, like long urls, are broken up.
+func wrapText(face font.Face, text string, width int) []string {
+	fits := func(s string) bool {
+		return font.MeasureString(face, s).Ceil() <= width
+	}
+
+	lines := []string{}
+	line := ""
+	for _, word := range strings.Fields(text) {
+		if line != "" && fits(line+" "+word) {
+			line += " " + word
+			continue
+		}
+
+		// Start a new line with the word, breaking it if it's too wide.
+		if line != "" {
+			lines = append(lines, line)
+			line = ""
+		}
+		for _, r := range word {
+			if line != "" && !fits(line+string(r)) {
+				lines = append(lines, line)
+				line = ""
+			}
+			line += string(r)
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// ellipsize adds an ellipsis to the given line and shortens it so that
+// it still fits in the given width when drawn with the given face.
+func ellipsize(face font.Face, line string, width int) string {
+	r := []rune(line)
+	for {
+		s := strings.TrimRightFunc(string(r), unicode.IsSpace) + "…"
+		if len(r) == 0 || font.MeasureString(face, s).Ceil() <= width {
+			return s
+		}
+		r = r[:len(r)-1]
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// elementRegex matches the elements that can link to something.
+var elementRegex = regexp.MustCompile(
+	`(?is)<(a|area|link|img|script|source|iframe|video|audio)\b[^>]*>`)
+
+// attrRegex matches the attributes of an element. Minified pages can
+// have attributes without values (e.g. alt).
+var attrRegex = regexp.MustCompile(
+	`\s([a-zA-Z-]+)(?:\s*=\s*(?:"([^"]*)"|'([^']*)'))?`)
+
+// anchorRegex matches the attributes that can be the target of a
+// fragment.
+var anchorRegex = regexp.MustCompile(
+	`\s(?:id|name)\s*=\s*(?:"([^"]*)"|'([^']*)')`)
+
+// Problem is something wrong with a generated page that was found by
+// Check.
+type Problem struct {
+	// Page is the path of the page relative to the output directory.
+	Page string
+
+	// Message describes what is wrong.
+	Message string
+}
+
+// String returns the page and message of the Problem.
+func (p Problem) String() string {
+	return p.Page + ": " + p.Message
+}
+
+// Check looks through all of the HTML pages in the given directory and
+// returns the problems it finds with them. It looks for internal links
+// and assets that don't exist, links to anchors that aren't on the
+// page they point to and images without alt text. Links that start
+// with the given site url are treated as internal.
+func Check(dir, site string) ([]Problem, error) {
+	pages := map[string]string{}
+
+	// Read all of the pages.
+	err := filepath.Walk(dir, func(p string, fi os.FileInfo,
+		err error) error {
+		if err != nil {
+			return err
+		}
+
+		ext := strings.ToLower(path.Ext(p))
+		if fi.IsDir() || (ext != ".html" && ext != ".htm") {
+			return nil
+		}
+
+		contents, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		pages[filepath.ToSlash(rel)] = string(contents)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Gather the anchors of each page.
+	anchors := map[string]map[string]bool{}
+	for page, contents := range pages {
+		anchors[page] = map[string]bool{}
+		for _, m := range anchorRegex.FindAllStringSubmatch(contents, -1) {
+			anchors[page][m[1]+m[2]] = true
+		}
+	}
+
+	problems := []Problem{}
+	for page, contents := range pages {
+		for _, el := range elementRegex.FindAllStringSubmatch(contents, -1) {
+			name := strings.ToLower(el[1])
+			attrs := map[string]string{}
+			for _, m := range attrRegex.FindAllStringSubmatch(el[0], -1) {
+				attrs[strings.ToLower(m[1])] = m[2] + m[3]
+			}
+
+			// Markdown images without alt text have an empty alt.
+			if name == "img" && strings.TrimSpace(attrs["alt"]) == "" {
+				problems = append(problems, Problem{page,
+					fmt.Sprintf("image %s has no alt text", attrs["src"])})
+			}
+
+			links := []string{attrs["href"], attrs["src"]}
+			for _, src := range strings.Split(attrs["srcset"], ",") {
+				if f := strings.Fields(src); len(f) > 0 {
+					links = append(links, f[0])
+				}
+			}
+
+			for _, link := range links {
+				msg := checkLink(dir, site, page, link, anchors)
+				if msg != "" {
+					problems = append(problems, Problem{page, msg})
+				}
+			}
+		}
+	}
+
+	sort.Sort(problemSlice(problems))
+
+	return problems, nil
+}
+
+// checkLink is a helper function for Check that checks a single link
+// on the given page. It returns a description of the problem or "" if
+// there isn't one.
+func checkLink(dir, site, page, link string,
+	anchors map[string]map[string]bool) string {
+
+	if link == "" {
+		return ""
+	}
+
+	// Links to our own site are internal.
+	if site != "" && strings.HasPrefix(link, site) {
+		link = "/" + strings.TrimPrefix(strings.TrimPrefix(link, site), "/")
+	}
+
+	// Ignore external links and things like mailto:.
+	if strings.HasPrefix(link, "//") || (!strings.HasPrefix(link, "/") &&
+		!isRelativeLink(link)) {
+		return ""
+	}
+
+	u, err := url.Parse(link)
+	if err != nil {
+		return fmt.Sprintf("invalid link %s", link)
+	}
+
+	// Figure out which file the link points to.
+	target := page
+	if u.Path != "" {
+		if strings.HasPrefix(u.Path, "/") {
+			target = path.Clean(strings.TrimPrefix(u.Path, "/"))
+		} else {
+			target = path.Join(path.Dir(page), u.Path)
+		}
+
+		if strings.HasPrefix(target, "../") || target == ".." {
+			return fmt.Sprintf("link %s is outside of the site", link)
+		}
+
+		fi, err := os.Stat(path.Join(dir, target))
+		if err == nil && fi.IsDir() {
+			target = path.Join(target, "index.html")
+			_, err = os.Stat(path.Join(dir, target))
+		}
+		if err != nil {
+			return fmt.Sprintf("broken link to %s", link)
+		}
+	}
+
+	// Make sure the anchor is there.
+	if u.Fragment != "" {
+		a, ok := anchors[target]
+		if ok && !a[u.Fragment] {
+			return fmt.Sprintf("missing anchor #%s in %s", u.Fragment,
+				target)
+		}
+	}
+
+	return ""
+}
+
+// problemSlice implements the sorting interface for go's sort package
+// so problems can be sorted by page.
+type problemSlice []Problem
+
+// Len returns the length of the problemSlice.
+func (p problemSlice) Len() int {
+	return len(p)
+}
+
+// Less returns true if the value at i is less than the value at j.
+func (p problemSlice) Less(i, j int) bool {
+	if p[i].Page != p[j].Page {
+		return p[i].Page < p[j].Page
+	}
+
+	return p[i].Message < p[j].Message
+}
+
+// Swap switches the elemens at i and j.
+func (p problemSlice) Swap(i, j int) {
+	p[i], p[j] = p[j], p[i]
+}
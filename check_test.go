@@ -0,0 +1,53 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestCheckAltText(t *testing.T) {
+	dir, err := ioutil.TempDir("", "goblog-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	page := "<html><body>" + markdown("![](beach.jpg)\n\n"+
+		"![The beach](sunset.jpg)\n\n![ ](sand.jpg)\n") + "</body></html>"
+	files := map[string]string{
+		"entry.html": page,
+		"beach.jpg":  "",
+		"sunset.jpg": "",
+		"sand.jpg":   "",
+	}
+	for name, contents := range files {
+		err = ioutil.WriteFile(path.Join(dir, name), []byte(contents), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	problems, err := Check(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"entry.html: image beach.jpg has no alt text",
+		"entry.html: image sand.jpg has no alt text",
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("Check = %v, want %v", problems, want)
+	}
+	for i, p := range problems {
+		if p.String() != want[i] {
+			t.Errorf("Check[%d] = %s, want %s", i, p, want[i])
+		}
+	}
+}
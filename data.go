@@ -0,0 +1,146 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// Data is the contents of the data files. It's available in every
+// template as .Data (see Helper.Data).
+var Data = map[string]interface{}{}
+
+// LoadData reads the JSON, YAML, TOML and CSV files in the given
+// directory into a tree. Each file is keyed by its name without its
+// extension and each directory is a tree of its own keyed by its name,
+// so data/talks/2013.yaml is {{index .Data.talks "2013"}}. The rows
+// of a CSV file are a list of maps keyed by the names in its first row.
+// It's fine if the directory doesn't exist.
+func LoadData(dir string) (map[string]interface{}, error) {
+	data := map[string]interface{}{}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return data, nil
+		}
+		return nil, err
+	}
+
+	for _, file := range files {
+		name := file.Name()
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		var value interface{}
+		key := strings.TrimSuffix(name, path.Ext(name))
+
+		if file.IsDir() {
+			key = name
+			value, err = LoadData(path.Join(dir, name))
+		} else {
+			value, err = readDataFile(path.Join(dir, name))
+			if value == nil && err == nil {
+				continue
+			}
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if _, ok := data[key]; ok {
+			return nil, fmt.Errorf("duplicate data: %s",
+				path.Join(dir, key))
+		}
+		data[key] = value
+	}
+
+	return data, nil
+}
+
+// readDataFile is a helper function for LoadData that reads a single
+// file based on its extension. Files that aren't data are nil.
+func readDataFile(file string) (interface{}, error) {
+	contents, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var value interface{}
+	switch strings.ToLower(path.Ext(file)) {
+	case ".json":
+		err = json.Unmarshal(contents, &value)
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(contents, &value)
+		value = stringKeys(value)
+	case ".toml":
+		m := map[string]interface{}{}
+		_, err = toml.Decode(string(contents), &m)
+		value = m
+	case ".csv":
+		value, err = readCSV(contents)
+	default:
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading data %s: %v", file, err)
+	}
+
+	return value, nil
+}
+
+// readCSV is a helper function for readDataFile that reads the rows of
+// a CSV file into maps keyed by the names in its first row.
+func readCSV(contents []byte) ([]map[string]string, error) {
+	records, err := csv.NewReader(bytes.NewReader(contents)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := []map[string]string{}
+	if len(records) == 0 {
+		return rows, nil
+	}
+
+	header := records[0]
+	for _, record := range records[1:] {
+		row := map[string]string{}
+		for i, name := range header {
+			row[strings.TrimSpace(name)] = record[i]
+		}
+		rows = append(rows, row)
+	}
+
+	return rows, nil
+}
+
+// stringKeys converts the maps YAML is decoded into so they are keyed
+// by strings like the rest of the data.
+func stringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		m := map[string]interface{}{}
+		for k, e := range v {
+			m[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return m
+	case []interface{}:
+		for i, e := range v {
+			v[i] = stringKeys(e)
+		}
+	}
+
+	return value
+}
@@ -6,10 +6,13 @@ package main
 
 import (
 	"bytes"
+	"fmt"
 	"github.com/russross/blackfriday"
 	"io/ioutil"
+	"os"
 	"path"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -24,22 +27,107 @@ type Entry struct {
 	// Path is the path to the markdown file from the cwd.
 	Path string
 
-	// Aurhor is the name of the person who wrote the page.
+	// Bundle is the path to the directory of a page bundle from the
+	// cwd. A page bundle is a directory that contains an index.md file
+	// along with the assets it uses. It's "" for normal entries.
+	Bundle string
+
+	// Aurhor is the name of the person who wrote the page. If there
+	// are co-authors, it's all of their names separated by commas.
 	Author string
 
+	// Authors are the profiles of the people who wrote the page. They
+	// are resolved from the Author meta data using the AuthorProfiles
+	// when the Parse method is called.
+	Authors []*Author
+
 	// Title is the title of the Entry.
 	Title string
 
 	// Description is the description of the Entry.
 	Description string
 
-	// Url is the HTML file name of this entry (Name + ".html").
+	// Url is the HTML file name of this entry (Name + ".html"). It's
+	// relative to the directory of the entry's Language.
 	Url string
 
+	// Section is the name of the content section the entry is in. It's
+	// "" for the entries in the BlogDir.
+	Section string
+
+	// Language is the language this version of the entry is written
+	// in. It's gleaned from the filename (e.g. post.de.md) and is the
+	// DefaultLanguage if the filename doesn't have one.
+	Language string
+
+	// Translations are the versions of this entry in the other
+	// languages. They are found by GetBlogFiles.
+	Translations []*Entry
+
 	// Tags is a list of tags this blog entry contains. It is generated
 	// when when the Parse method is called.
 	Tags []string
 
+	// Terms are the terms of this blog entry in each of the Taxonomies
+	// keyed by the taxonomy. It is generated when the Parse method is
+	// called.
+	Terms map[string][]string
+
+	// Pinned determines whether or not this blog entry stays at the top
+	// of the index. It is generated when the Parse method is called.
+	Pinned bool
+
+	// Weight is used to order the entries by hand. Lighter entries come
+	// first. It is generated when the Parse method is called.
+	Weight int
+
+	// Password is the password needed to read this blog entry. If it's
+	// set, the content of its page is encrypted and it isn't shown
+	// anywhere else. It is generated when the Parse method is called.
+	Password string
+
+	// Unlisted determines whether or not this blog entry is left out of
+	// the lists of entries and the feeds. It still gets a page. It is
+	// generated when the Parse method is called.
+	Unlisted bool
+
+	// Expires is when this blog entry is removed from the site. Its page
+	// becomes a page saying it's gone. It is generated when the Parse
+	// method is called.
+	Expires time.Time
+
+	// SeriesName is the name of the series this blog entry is a part
+	// of. It is generated when the Parse method is called.
+	SeriesName string
+
+	// SeriesOrder is the position of this blog entry within its
+	// series. It is generated when the Parse method is called.
+	SeriesOrder int
+
+	// Series is the place of this blog entry within its series or nil
+	// if it isn't part of one. It is set by GetSeries.
+	Series *SeriesPart
+
+	// Image is the url of the featured image of the blog entry relative
+	// to the root of the site or "" if it doesn't have one. ImageAlt
+	// describes it. They are generated when the Parse method is called.
+	Image    string
+	ImageAlt string
+
+	// Thumbnail is the small version of the featured image that's
+	// cropped to ThumbnailWidth and ThumbnailHeight or nil if there's
+	// no featured image.
+	Thumbnail *ImageVariant
+
+	// imageFile is the path to the featured image from the cwd.
+	imageFile string
+
+	// source is the markdown of the entry read by ReadInfo.
+	source []byte
+
+	// content is the HTML of the entry made by Parse.
+	content string
+
 	// Languages is a list of languages this blog entry contains. It is
 	// generated when when the Parse method is called.
 	Languages []string
@@ -51,51 +139,230 @@ type Entry struct {
 	// Updated is the date the blog entry was last updated. It is
 	// generated when the Parse metod is called.
 	Updated time.Time
+
+	// Summary is the HTML formatted excerpt of the blog entry. It is
+	// everything before the <!--more--> marker or, if there isn't one,
+	// the first SummaryWords words of the entry. It is generated when
+	// the Parse method is called.
+	Summary string
+
+	// Truncated is true if the Summary doesn't contain the entire
+	// blog entry. It is generated when the Parse method is called.
+	Truncated bool
+
+	// WordCount is the number of words in the rendered blog entry. It
+	// is generated when the Parse method is called.
+	WordCount int
+
+	// ReadingTime is the estimated number of minutes it takes to read
+	// the blog entry. It is generated when the Parse method is called.
+	ReadingTime int
 }
 
-// Parse reads the contents of the path for this Entry. It gleans
-// information from the file and saves it to this Entry. It then
-// formats the markdown to HTML and returns that.
-func (e *Entry) Parse() (string, error) {
+// ReadInfo reads the contents of the path for this Entry. It gleans
+// information from the file and saves it to this Entry without
+// rendering it, so it's known which entries have expired and what
+// assets they have before any of them are rendered.
+func (e *Entry) ReadInfo() error {
 	// Get the files contents.
-	orgContents, err := ioutil.ReadFile(e.Path)
+	contents, err := ioutil.ReadFile(e.Path)
 	if err != nil {
-		return "", err
+		return err
 	}
 
 	// Save some of the meta data.
-	err = e.gleanInfo(string(orgContents))
+	err = e.gleanInfo(string(contents))
+	if err != nil {
+		return err
+	}
+
+	e.source = contents
+	return nil
+}
+
+// Parse formats the markdown of this Entry to HTML and returns that.
+// The HTML is also kept for the pages that show the entry, so it only
+// needs to be done once. If ReadInfo hasn't been called yet, it's
+// called first.
+func (e *Entry) Parse() (string, error) {
+	if e.source == nil {
+		err := e.ReadInfo()
+		if err != nil {
+			return "", err
+		}
+	}
+	orgContents := e.source
+
+	// Generate the HTML content.
+	content, err := e.render(orgContents)
+	if err != nil {
+		return "", err
+	}
+
+	// Gather the statistics from what the reader will actually see.
+	e.WordCount = countWords(PlainText(content))
+	e.ReadingTime = ReadingTime(e.WordCount)
+
+	// Make the summary. An explicit marker wins over the word limit.
+	if more, ok := SplitMore(orgContents); ok {
+		summary, err := e.render(more)
+		if err != nil {
+			return "", err
+		}
+		e.Summary = StripComments(summary)
+		e.Truncated = true
+	} else {
+		e.Summary, e.Truncated = TruncateHTML(StripComments(content),
+			SummaryWords)
+	}
+
+	// Only the page of a protected entry has its content.
+	if e.Protected() {
+		e.Summary = ""
+		e.Truncated = true
+	}
+
+	e.content = content
+	return content, nil
+}
+
+// render converts the given markdown to HTML. The shortcodes are
+// rendered first and then the rest with renderMarkdown.
+func (e *Entry) render(markdown []byte) (string, error) {
+	ph := &placeholders{}
+	md, err := RenderShortcodes(string(markdown), e, ph)
 	if err != nil {
 		return "", err
 	}
 
-	// Return the markdown content.
-	return string(blackfriday.MarkdownCommon(orgContents)), nil
+	return e.renderMarkdown(md, ph), nil
+}
+
+// renderMarkdown converts the given markdown without shortcodes to
+// HTML. The math is rendered first and the given placeholders are
+// restored afterwards. Links to the assets of a page bundle are fixed
+// and then srcset attributes are added for any of our Images.
+func (e *Entry) renderMarkdown(md string, ph *placeholders) string {
+	md = RenderMath(md, ph)
+
+	content := string(blackfriday.MarkdownCommon([]byte(md)))
+	content = ph.restore(content)
+	content = e.rewriteBundleLinks(content)
+	return Images.Rewrite(content, e.pageDir())
+}
+
+// pageDir returns the directory of the entry's page relative to the
+// OutputDir.
+func (e *Entry) pageDir() string {
+	return path.Dir(path.Join(LanguageDir(e.Language), e.Url))
+}
+
+// SiteUrl returns the url of this entry relative to the root of the
+// site rather than the directory of its language.
+func (e *Entry) SiteUrl() string {
+	return path.Join(LanguageDir(e.Language), e.Url)
+}
+
+// Expired returns true if the entry has expired and should be removed
+// from the site.
+func (e *Entry) Expired() bool {
+	return !e.Expires.IsZero() && !time.Now().Before(e.Expires)
+}
+
+// RemoveFiles removes the files made for the entry in the given
+// directory by an earlier build: the assets of its page bundle, its
+// social card and the thumbnail of its featured image. It's used for
+// entries that have expired when the OutputDir isn't emptied first.
+func (e *Entry) RemoveFiles(dir string) error {
+	files := []string{path.Join(dir, LanguageDir(e.Language), e.CardUrl())}
+	if e.Bundle != "" {
+		files = append(files, path.Join(dir, e.assetDir()))
+	}
+	if e.Thumbnail != nil {
+		files = append(files, path.Join(dir, e.Thumbnail.Url))
+	}
+
+	for _, file := range files {
+		err := os.RemoveAll(file)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Protected returns true if a password is needed to read the entry.
+func (e *Entry) Protected() bool {
+	return e.Password != ""
+}
+
+// Listed returns true if the entry belongs in the lists of entries and
+// the feeds.
+func (e *Entry) Listed() bool {
+	return !e.Unlisted && !e.Expired()
+}
+
+// TermsOf returns the terms of this entry in the given taxonomy.
+func (e *Entry) TermsOf(taxonomy string) []string {
+	if taxonomy == "tags" {
+		return e.Tags
+	}
+
+	return e.Terms[taxonomy]
+}
+
+// Versions returns this entry and all of its Translations.
+func (e *Entry) Versions() []*Entry {
+	return append([]*Entry{e}, e.Translations...)
+}
+
+// ReadingTime returns the number of minutes it takes to read the given
+// number of words at WordsPerMinute. Anything with words in it takes
+// at least a minute.
+func ReadingTime(words int) int {
+	if words <= 0 {
+		return 0
+	}
+
+	wpm := WordsPerMinute
+	if wpm <= 0 {
+		wpm = 200
+	}
+
+	return (words + wpm - 1) / wpm
 }
 
 // CDate is a helper function for the templating system that returns
-// the Created date as a string or "" if there is no value.
+// the Created date as a string or "" if there is no value. It uses the
+// date format of the entry's Locale and the site's Location.
 func (e *Entry) CDate() string {
 	if e.Created.IsZero() {
 		return ""
 	}
 
-	return e.Created.Format("2006-01-02")
+	l := GetLocale(e.Language)
+	return l.Format(e.Created.In(Location), l.DateFormat)
 }
 
 // PubDate is a helper function for the templating system that returns
-// the Created date as an RFC822 string or "" if there is no value.
+// the Created date as an RFC822 string in the site's Location or "" if
+// there is no value. The layout can be changed by the entry's Locale,
+// but because it's meant for feeds, the names of months and days are
+// always in English.
 func (e *Entry) PubDate() string {
 	if e.Created.IsZero() {
 		return ""
 	}
 
-	return e.Created.Format("02 Jan 2006 15:04 MST")
+	return e.Created.In(Location).Format(
+		GetLocale(e.Language).PubDateFormat)
 }
 
 // UDate is a helper function for the templating system that returns
 // the Updated date as a string or "" if there is no value or if it's
-// identical to the Created date.
+// identical to the Created date. It uses the date format of the
+// entry's Locale.
 func (e *Entry) UDate() string {
 	if e.Updated.IsZero() {
 		return ""
@@ -105,16 +372,46 @@ func (e *Entry) UDate() string {
 		return ""
 	}
 
-	return e.Updated.Format("2006-01-02")
+	l := GetLocale(e.Language)
+	return l.Format(e.Updated.In(Location), l.DateFormat)
 }
 
-// GetBlogFiles looks in the given directory for blog entries and
-// returns a list of them. Blog entries must have the '.md'
-// extension. Entries are searched in the directory recursively. If a
-// files is in a directory, the directory name is used as a prefix to
-// the blog entries name concatenated with a '-'. The blog is not
-// parsed or read. You should do that yourself elsewhere.
-func GetBlogFiles(dir string) ([]*Entry, error) {
+// GetBlogFiles looks in the directory of each of the given sections
+// for blog entries and returns a list of them. Blog entries must have
+// the '.md' extension. Entries are searched in the directory
+// recursively. If a files is in a directory, the directory name is
+// used as a prefix to the blog entries name concatenated with a '-'. A
+// directory that contains an index.md file is a page bundle and
+// becomes a single entry named after the directory; the rest of its
+// files are the entry's assets. The entries of a section other than
+// the blog are put in a directory with the section's name. If the site
+// is multilingual, the versions of an entry in each language (e.g.
+// post.en.md and post.de.md) are grouped into a single entry using
+// GroupTranslations. The blog is not parsed or read. You should do
+// that yourself elsewhere.
+func GetBlogFiles(sections []*Section) ([]*Entry, error) {
+	entries := []*Entry{}
+
+	for _, s := range sections {
+		blogs, err := getBlogFiles(s.Dir)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, blog := range blogs {
+			blog.Section = s.Name
+			blog.Url = path.Join(s.Name, blog.Url)
+		}
+
+		entries = append(entries, blogs...)
+	}
+
+	return GroupTranslations(entries), nil
+}
+
+// getBlogFiles is a helper function for GetBlogFiles that finds all of
+// the entries in the given directory recursively.
+func getBlogFiles(dir string) ([]*Entry, error) {
 	entries := []*Entry{}
 
 	// Read the list of entries for dir.
@@ -129,7 +426,32 @@ func GetBlogFiles(dir string) ([]*Entry, error) {
 		// If it's a directory, then recursively call this function and
 		// merge the two slices.
 		if file.IsDir() {
-			blogs, err := GetBlogFiles(p)
+			// Page bundles are a single entry.
+			indexes, err := bundleIndexes(p)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(indexes) > 0 {
+				newName, err := MakeBlogName(file.Name())
+				if err != nil {
+					return nil, err
+				}
+
+				for _, index := range indexes {
+					_, lang := splitLanguage(path.Base(index))
+					entries = append(entries, &Entry{
+						Name:     newName,
+						Url:      newName + ".html",
+						Path:     index,
+						Bundle:   p,
+						Language: lang,
+					})
+				}
+				continue
+			}
+
+			blogs, err := getBlogFiles(p)
 			if err != nil {
 				return nil, err
 			}
@@ -142,9 +464,11 @@ func GetBlogFiles(dir string) ([]*Entry, error) {
 				}
 
 				entries = append(entries, &Entry{
-					Name: newName,
-					Url:  newName + ".html",
-					Path: blog.Path,
+					Name:     newName,
+					Url:      newName + ".html",
+					Path:     blog.Path,
+					Bundle:   blog.Bundle,
+					Language: blog.Language,
 				})
 
 			}
@@ -154,15 +478,15 @@ func GetBlogFiles(dir string) ([]*Entry, error) {
 				continue
 			}
 
-			// Get the name of the entry.
-			pieces := strings.Split(file.Name(), ".md")
-			newName := pieces[0]
+			// Get the name and language of the entry.
+			newName, lang := splitLanguage(file.Name())
 
 			// Just create the new entry.
 			entries = append(entries, &Entry{
-				Name: newName,
-				Url:  newName + ".html",
-				Path: p,
+				Name:     newName,
+				Url:      newName + ".html",
+				Path:     p,
+				Language: lang,
 			})
 		}
 	}
@@ -212,6 +536,18 @@ func (be *Entry) gleanInfo(contents string) error {
 		return err
 	}
 
+	be.Authors, err = ResolveAuthors(be.Author)
+	if err != nil {
+		return err
+	}
+
+	// Use the real names of the authors rather than their IDs.
+	names := []string{}
+	for _, a := range be.Authors {
+		names = append(names, a.Name)
+	}
+	be.Author = strings.Join(names, ", ")
+
 	be.Description, err = regexSingle("Description", contents)
 	if err != nil {
 		return err
@@ -226,6 +562,95 @@ func (be *Entry) gleanInfo(contents string) error {
 	if err != nil {
 		return err
 	}
+	be.Tags = ResolveTags(be.Tags)
+
+	be.Terms = map[string][]string{}
+	for _, taxonomy := range Taxonomies {
+		terms, err := regexList(TaxonomyKey(taxonomy), contents)
+		if err != nil {
+			return err
+		}
+		be.Terms[taxonomy] = resolveTerms(nil, terms)
+	}
+
+	pinned, err := regexSingle("Pinned", contents)
+	if err != nil {
+		return err
+	}
+	if pinned != "" {
+		be.Pinned, err = strconv.ParseBool(pinned)
+		if err != nil {
+			return fmt.Errorf("invalid Pinned: %s", pinned)
+		}
+	}
+
+	weight, err := regexSingle("Weight", contents)
+	if err != nil {
+		return err
+	}
+	if weight != "" {
+		be.Weight, err = strconv.Atoi(weight)
+		if err != nil {
+			return fmt.Errorf("invalid Weight: %s", weight)
+		}
+	}
+
+	be.Password, err = regexSingle("Password", contents)
+	if err != nil {
+		return err
+	}
+
+	unlisted, err := regexSingle("Unlisted", contents)
+	if err != nil {
+		return err
+	}
+	if unlisted != "" {
+		be.Unlisted, err = strconv.ParseBool(unlisted)
+		if err != nil {
+			return fmt.Errorf("invalid Unlisted: %s", unlisted)
+		}
+	}
+
+	expires, err := regexSingle("Expires", contents)
+	if err != nil {
+		return err
+	}
+	if expires != "" {
+		be.Expires, err = ParseTime(expires)
+		if err != nil {
+			return fmt.Errorf("invalid Expires: %s", expires)
+		}
+	}
+
+	be.SeriesName, err = regexSingle("Series", contents)
+	if err != nil {
+		return err
+	}
+
+	order, err := regexSingle("SeriesOrder", contents)
+	if err != nil {
+		return err
+	}
+	if order != "" {
+		be.SeriesOrder, err = strconv.Atoi(order)
+		if err != nil {
+			return fmt.Errorf("invalid SeriesOrder: %s", order)
+		}
+	}
+
+	image, err := regexSingle("Image", contents)
+	if err != nil {
+		return err
+	}
+	err = be.resolveImage(image)
+	if err != nil {
+		return err
+	}
+
+	be.ImageAlt, err = regexSingle("ImageAlt", contents)
+	if err != nil {
+		return err
+	}
 
 	created, updated, err := GetTimes(be.Path)
 	if err != nil {
@@ -233,20 +658,48 @@ func (be *Entry) gleanInfo(contents string) error {
 	}
 
 	_created, err := regexSingle("Created", contents)
-	be.Created, err = time.Parse("2006-01-02", _created)
+	be.Created, err = ParseTime(_created)
 	if err != nil {
-		be.Created = created
+		be.Created = created.In(Location)
 	}
 
 	_updated, err := regexSingle("Updated", contents)
-	be.Updated, err = time.Parse("2006-01-02", _updated)
+	be.Updated, err = ParseTime(_updated)
 	if err != nil {
-		be.Updated = updated
+		be.Updated = updated.In(Location)
 	}
 
 	return nil
 }
 
+// timeLayouts are the layouts ParseTime accepts in the order they are
+// tried.
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// ParseTime parses the value of a Created, Updated or Expires field.
+// It can be a full RFC 3339 timestamp (2013-07-18T14:30:00-06:00), a
+// date and time (2013-07-18 14:30) or just a date (2013-07-18). Values
+// without a time zone are in the site's Location.
+func ParseTime(value string) (time.Time, error) {
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		t, err = time.ParseInLocation(layout, value, Location)
+		if err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, err
+}
+
 // regexList is a helper function that performs a regex search for an
 // HTML comment with the given title. It returns the list (comma
 // separated) of values.
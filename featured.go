@@ -0,0 +1,164 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// ThumbnailWidth and ThumbnailHeight are the size of the thumbnails of
+// the featured images of the entries in pixels.
+var ThumbnailWidth, ThumbnailHeight int
+
+// thumbnailDir is the directory in the OutputDir the thumbnails are
+// written to, so they can't overwrite the files from the StaticDir.
+const thumbnailDir = "thumbnails"
+
+// thumbnailUrl returns the url of the thumbnail of the image with the
+// given url. It's the path of the image in the thumbnailDir with the
+// size of the thumbnail added to its name (e.g.
+// thumbnails/images/beach-480x270.jpg for images/beach.jpg).
+func thumbnailUrl(image string) string {
+	ext := path.Ext(image)
+	return path.Join(thumbnailDir, fmt.Sprintf("%s-%dx%d%s",
+		strings.TrimSuffix(image, ext), ThumbnailWidth, ThumbnailHeight,
+		ext))
+}
+
+// ParseThumbnailSize converts a size like 480x270 into its width and
+// height.
+func ParseThumbnailSize(s string) (int, int, error) {
+	parts := strings.Split(s, "x")
+	if len(parts) != 2 {
+		return 0, 0, fmt.Errorf("invalid thumbnail size: %s", s)
+	}
+
+	w, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || w <= 0 {
+		return 0, 0, fmt.Errorf("invalid thumbnail size: %s", s)
+	}
+
+	h, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+	if err != nil || h <= 0 {
+		return 0, 0, fmt.Errorf("invalid thumbnail size: %s", s)
+	}
+
+	return w, h, nil
+}
+
+// resolveImage finds the featured image with the given path. It's
+// looked for alongside the entry if it's a page bundle and then in the
+// StaticDir. Paths that start with / are only looked for in the
+// StaticDir. It sets the Image, imageFile and Thumbnail of the entry.
+func (e *Entry) resolveImage(image string) error {
+	e.Image, e.imageFile, e.Thumbnail = "", "", nil
+	if image == "" {
+		return nil
+	}
+
+	if imageFormat(image) == "" {
+		return fmt.Errorf("image isn't a JPEG or PNG: %s", image)
+	}
+
+	clean := path.Clean("/" + image)[1:]
+	if e.Bundle != "" && !strings.HasPrefix(image, "/") {
+		file := path.Join(e.Bundle, clean)
+		if _, err := os.Stat(file); err == nil {
+			e.Image, e.imageFile = path.Join(e.assetDir(), clean), file
+		}
+	}
+
+	if e.Image == "" {
+		file := path.Join(StaticDir, clean)
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("image not found: %s", image)
+		}
+		e.Image, e.imageFile = clean, file
+	}
+
+	e.Thumbnail = &ImageVariant{
+		Url:    thumbnailUrl(e.Image),
+		Width:  ThumbnailWidth,
+		Height: ThumbnailHeight,
+	}
+
+	return nil
+}
+
+// MakeThumbnail creates the thumbnail of the entry's featured image
+// in the given directory.
+func (e *Entry) MakeThumbnail(dir string) error {
+	if e.Thumbnail == nil {
+		return nil
+	}
+
+	dest := path.Join(dir, e.Thumbnail.Url)
+	err := MakeDirIfNotExists(path.Dir(dest))
+	if err != nil {
+		return err
+	}
+
+	return makeThumbnail(dest, e.imageFile, e.Thumbnail)
+}
+
+// makeThumbnail writes the image at src to dest cropped to fill the
+// size of the given thumbnail. Thumbnails are kept in the CacheDir like
+// the other images.
+func makeThumbnail(dest, src string, thumb *ImageVariant) error {
+	contents, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	sum := sha1.Sum(contents)
+	name := fmt.Sprintf("%s-thumb-%dx%d.%s", hex.EncodeToString(sum[:]),
+		thumb.Width, thumb.Height, imageFormat(src))
+
+	return cachedImage(dest, name, func() (image.Image, error) {
+		img, _, err := image.Decode(bytes.NewReader(contents))
+		if err != nil {
+			return nil, err
+		}
+
+		return cover(orient(img, exifOrientation(contents)), thumb.Width,
+			thumb.Height), nil
+	})
+}
+
+// Enclosure is a file attached to an item of a feed.
+type Enclosure struct {
+	Url    string
+	Length int64
+	Type   string
+}
+
+// enclosure returns the featured image of the given entry as an
+// Enclosure or nil if it doesn't have one. Its url is relative to the
+// site.
+func enclosure(e *Entry) *Enclosure {
+	if e.Image == "" {
+		return nil
+	}
+
+	fi, err := os.Stat(path.Join(OutputDir, e.Image))
+	if err != nil {
+		return nil
+	}
+
+	return &Enclosure{
+		Url:    e.Image,
+		Length: fi.Size(),
+		Type:   "image/" + imageFormat(e.Image),
+	}
+}
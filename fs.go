@@ -16,13 +16,14 @@ import (
 	"time"
 )
 
-// MakeDirIfNotExists creates the given directory if it does not exist.
+// MakeDirIfNotExists creates the given directory and any of its parents
+// if they do not exist.
 func MakeDirIfNotExists(dir string) error {
 	st, err := os.Stat(dir)
 	if err != nil {
 		// It may just not exist. Make it or error out trying.
 		if os.IsNotExist(err) {
-			return os.Mkdir(dir, 0750)
+			return os.MkdirAll(dir, 0750)
 		}
 	}
 
@@ -0,0 +1,386 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"github.com/rwcarlsen/goexif/exif"
+	"image"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Galleries are the photo galleries of the site. They are found by
+// GetGalleries.
+var Galleries []*Gallery
+
+// Gallery is a directory of photos in the GalleryDir.
+type Gallery struct {
+	// Name is the name of the gallery's directory. It's also used for
+	// its urls.
+	Name string
+
+	// Title is the title of the gallery. It defaults to the Name.
+	Title string
+
+	// Description is a little bit about the gallery.
+	Description string
+
+	// Photos are the photos in the gallery ordered by their file name.
+	Photos []*Photo
+
+	// dir is the path to the gallery's directory from the cwd.
+	dir string
+}
+
+// Url returns the url of the gallery's page relative to the root of
+// the site in the page's language.
+func (g *Gallery) Url() string {
+	return path.Join(path.Base(GalleryDir), g.Name, "index.html")
+}
+
+// Cover returns the first photo of the gallery or nil if it's empty.
+func (g *Gallery) Cover() *Photo {
+	if len(g.Photos) == 0 {
+		return nil
+	}
+
+	return g.Photos[0]
+}
+
+// Photo is a photo in a Gallery. What's known about how it was taken
+// comes from its EXIF data.
+type Photo struct {
+	// Name is the file name of the photo without its extension.
+	Name string
+
+	// Gallery is the gallery the photo is in.
+	Gallery *Gallery
+
+	// Image is the url of the photo relative to the root of the site.
+	Image string
+
+	// Thumbnail is the photo cropped to ThumbnailWidth and
+	// ThumbnailHeight.
+	Thumbnail *ImageVariant
+
+	// Width and Height are the size of the photo in pixels.
+	Width  int
+	Height int
+
+	// Taken is when the photo was taken or zero if it's unknown.
+	Taken time.Time
+
+	// Camera is the make and model of the camera that took the photo.
+	Camera string
+
+	// Exposure describes the exposure time, aperture, ISO and focal
+	// length of the photo (e.g. 1/250 s · f/8 · ISO 100 · 35 mm).
+	Exposure string
+
+	// Prev and Next are the photos before and after this one in the
+	// gallery or nil.
+	Prev *Photo
+	Next *Photo
+
+	// file is the path to the photo from the cwd.
+	file string
+}
+
+// Url returns the url of the photo's page relative to the root of the
+// site in the page's language.
+func (p *Photo) Url() string {
+	return path.Join(path.Dir(p.Gallery.Url()), p.Name+".html")
+}
+
+// Index returns the number of the photo in its gallery starting at 1.
+func (p *Photo) Index() int {
+	for i, o := range p.Gallery.Photos {
+		if o == p {
+			return i + 1
+		}
+	}
+
+	return 0
+}
+
+// GetGalleries finds the galleries in the given directory. Each
+// directory in it with photos is a gallery. Its title and description
+// can be given in a gallery.toml file in the directory. It's fine if
+// the directory doesn't exist.
+func GetGalleries(dir string) ([]*Gallery, error) {
+	galleries := []*Gallery{}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return galleries, nil
+		}
+		return nil, err
+	}
+
+	for _, file := range files {
+		if !file.IsDir() {
+			continue
+		}
+
+		slug, err := MakeBlogName(file.Name())
+		if err != nil {
+			return nil, err
+		}
+		if slug != file.Name() {
+			return nil, fmt.Errorf("invalid gallery name: %s", file.Name())
+		}
+
+		g := &Gallery{
+			Name:   file.Name(),
+			Title:  file.Name(),
+			Photos: []*Photo{},
+			dir:    path.Join(dir, file.Name()),
+		}
+
+		// Only the title and description come from the gallery.toml.
+		var meta struct {
+			Title       string `toml:"title"`
+			Description string `toml:"description"`
+		}
+		_, err = toml.DecodeFile(path.Join(g.dir, "gallery.toml"), &meta)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("gallery %s: %v", g.Name, err)
+		}
+		if meta.Title != "" {
+			g.Title = meta.Title
+		}
+		g.Description = meta.Description
+
+		err = g.findPhotos()
+		if err != nil {
+			return nil, fmt.Errorf("gallery %s: %v", g.Name, err)
+		}
+
+		if len(g.Photos) > 0 {
+			galleries = append(galleries, g)
+		}
+	}
+
+	return galleries, nil
+}
+
+// findPhotos reads the photos in the gallery's directory. It's an
+// error for a photo's page to be the gallery's page or the page of
+// another photo (e.g. beach.jpg and beach.png).
+func (g *Gallery) findPhotos() error {
+	files, err := ioutil.ReadDir(g.dir)
+	if err != nil {
+		return err
+	}
+
+	pages := map[string]string{path.Base(g.Url()): "the gallery"}
+	for _, file := range files {
+		if file.IsDir() || imageFormat(file.Name()) == "" {
+			continue
+		}
+
+		p, err := readPhoto(g, file.Name())
+		if err != nil {
+			return fmt.Errorf("%s: %v", file.Name(), err)
+		}
+
+		page := path.Base(p.Url())
+		if other, ok := pages[page]; ok {
+			return fmt.Errorf("photo %s and %s have the same url: %s",
+				file.Name(), other, p.Url())
+		}
+		pages[page] = file.Name()
+
+		if n := len(g.Photos); n > 0 {
+			p.Prev, g.Photos[n-1].Next = g.Photos[n-1], p
+		}
+		g.Photos = append(g.Photos, p)
+	}
+
+	return nil
+}
+
+// readPhoto is a helper function for findPhotos that reads a single
+// photo.
+func readPhoto(g *Gallery, name string) (*Photo, error) {
+	p := &Photo{
+		Name:    strings.TrimSuffix(name, path.Ext(name)),
+		Gallery: g,
+		Image:   path.Join(path.Dir(g.Url()), name),
+		file:    path.Join(g.dir, name),
+	}
+
+	p.Thumbnail = &ImageVariant{
+		Url:    thumbnailUrl(p.Image),
+		Width:  ThumbnailWidth,
+		Height: ThumbnailHeight,
+	}
+
+	contents, err := ioutil.ReadFile(p.file)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(contents))
+	if err != nil {
+		return nil, err
+	}
+	p.Width, p.Height = cfg.Width, cfg.Height
+	if exifOrientation(contents) >= 5 {
+		p.Width, p.Height = p.Height, p.Width
+	}
+
+	// The EXIF data is optional.
+	x, err := exif.Decode(bytes.NewReader(contents))
+	if err != nil {
+		return p, nil
+	}
+
+	p.Taken = exifTime(x)
+	p.Camera = exifCamera(x)
+	p.Exposure = exifExposure(x)
+
+	return p, nil
+}
+
+// exifString returns the value of the given EXIF string field or "".
+func exifString(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+
+	s, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+
+	return strings.TrimSpace(strings.Trim(s, "\x00"))
+}
+
+// exifTime returns when the photo was taken. EXIF times don't have a
+// time zone, so they are in the site's Location.
+func exifTime(x *exif.Exif) time.Time {
+	for _, name := range []exif.FieldName{exif.DateTimeOriginal,
+		exif.DateTime} {
+		s := exifString(x, name)
+		if s == "" {
+			continue
+		}
+
+		t, err := time.ParseInLocation("2006:01:02 15:04:05", s, Location)
+		if err == nil {
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// exifCamera returns the make and model of the camera. Models usually
+// start with the make already.
+func exifCamera(x *exif.Exif) string {
+	maker := exifString(x, exif.Make)
+	model := exifString(x, exif.Model)
+
+	if maker == "" || strings.HasPrefix(strings.ToLower(model),
+		strings.ToLower(maker)) {
+		return model
+	}
+	if model == "" {
+		return maker
+	}
+
+	return maker + " " + model
+}
+
+// exifExposure describes the exposure time, aperture, ISO and focal
+// length of the photo. The ones that are unknown are left out.
+func exifExposure(x *exif.Exif) string {
+	parts := []string{}
+
+	if tag, err := x.Get(exif.ExposureTime); err == nil {
+		if num, den, err := tag.Rat2(0); err == nil && num > 0 && den > 0 {
+			if num < den {
+				parts = append(parts, fmt.Sprintf("1/%d s",
+					(den+num/2)/num))
+			} else {
+				parts = append(parts, fmt.Sprintf("%g s",
+					float64(num)/float64(den)))
+			}
+		}
+	}
+
+	if tag, err := x.Get(exif.FNumber); err == nil {
+		if num, den, err := tag.Rat2(0); err == nil && den > 0 {
+			parts = append(parts, fmt.Sprintf("f/%g",
+				float64(num)/float64(den)))
+		}
+	}
+
+	if tag, err := x.Get(exif.ISOSpeedRatings); err == nil {
+		if iso, err := tag.Int(0); err == nil {
+			parts = append(parts, fmt.Sprintf("ISO %d", iso))
+		}
+	}
+
+	if tag, err := x.Get(exif.FocalLength); err == nil {
+		if num, den, err := tag.Rat2(0); err == nil && den > 0 {
+			parts = append(parts, fmt.Sprintf("%g mm",
+				float64(num)/float64(den)))
+		}
+	}
+
+	return strings.Join(parts, " · ")
+}
+
+// CopyPhotos copies the photos of the gallery into the given directory
+// and makes their thumbnails. The photos are processed like the images
+// in the StaticDir, so their EXIF data is removed.
+func (g *Gallery) CopyPhotos(dir string) error {
+	dest := path.Join(dir, path.Dir(g.Url()))
+	err := MakeDirIfNotExists(dest)
+	if err != nil {
+		return err
+	}
+
+	err = CopyFilesRecursively(dest, g.dir)
+	if err != nil {
+		return err
+	}
+
+	// The description of the gallery isn't part of it.
+	err = os.Remove(path.Join(dest, "gallery.toml"))
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	err = Images.Process(dest, g.dir, path.Dir(g.Url())+"/")
+	if err != nil {
+		return err
+	}
+
+	for _, p := range g.Photos {
+		thumb := path.Join(dir, p.Thumbnail.Url)
+		err = MakeDirIfNotExists(path.Dir(thumb))
+		if err != nil {
+			return err
+		}
+
+		err = makeThumbnail(thumb, p.file, p.Thumbnail)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
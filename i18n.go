@@ -0,0 +1,209 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// Locales are the translations of the user interface and date formats
+// for each language keyed by the language.
+var Locales = map[string]*Locale{}
+
+// Locale contains the translations of the user interface strings for
+// a language and how dates should be displayed in it. It's loaded from
+// a TOML file in the I18nDir (e.g. i18n/de.toml):
+//
+//      date_format = "2. January 2006"
+//      months = ["Januar", "Februar", "März", ...]
+//      days = ["Sonntag", "Montag", "Dienstag", ...]
+//
+//      [strings]
+//      About = "Über mich"
+//      "Read more" = "Weiterlesen"
+//
+// Anything not given uses the English default.
+type Locale struct {
+	// Strings are the translations of the user interface strings.
+	Strings map[string]string `toml:"strings"`
+
+	// Months are the names of the months starting with January.
+	Months []string `toml:"months"`
+
+	// ShortMonths are the abbreviated names of the months. If they
+	// aren't given, the first three letters of the Months are used.
+	ShortMonths []string `toml:"short_months"`
+
+	// Days are the names of the days of the week starting with Sunday.
+	Days []string `toml:"days"`
+
+	// ShortDays are the abbreviated names of the days of the week. If
+	// they aren't given, the first three letters of the Days are used.
+	ShortDays []string `toml:"short_days"`
+
+	// DateFormat is the layout used by CDate and UDate.
+	DateFormat string `toml:"date_format"`
+
+	// PubDateFormat is the layout used by PubDate.
+	PubDateFormat string `toml:"pub_date_format"`
+
+	// MonthFormat is the layout used for the months of the archive.
+	MonthFormat string `toml:"month_format"`
+
+	// MonthYearFormat is the layout used for the titles of the pages
+	// of each month of the archive.
+	MonthYearFormat string `toml:"month_year_format"`
+}
+
+// defaultLocale returns the English Locale that is used for anything
+// that isn't translated.
+func defaultLocale() *Locale {
+	l := &Locale{
+		Strings:         map[string]string{},
+		Months:          []string{},
+		Days:            []string{},
+		DateFormat:      "2006-01-02",
+		PubDateFormat:   "02 Jan 2006 15:04 MST",
+		MonthFormat:     "January",
+		MonthYearFormat: "January 2006",
+	}
+
+	for m := time.January; m <= time.December; m++ {
+		l.Months = append(l.Months, m.String())
+	}
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		l.Days = append(l.Days, d.String())
+	}
+
+	return l
+}
+
+// LoadLocales reads a Locale for each TOML file in the given
+// directory. The name of the file is the language. It's fine if the
+// directory doesn't exist.
+func LoadLocales(dir string) (map[string]*Locale, error) {
+	locales := map[string]*Locale{}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return locales, nil
+		}
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || path.Ext(file.Name()) != ".toml" {
+			continue
+		}
+
+		l := defaultLocale()
+		_, err = toml.DecodeFile(path.Join(dir, file.Name()), l)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", file.Name(), err)
+		}
+
+		if len(l.Months) != 12 || len(l.Days) != 7 {
+			return nil, fmt.Errorf("%s: need 12 months and 7 days",
+				file.Name())
+		}
+
+		locales[strings.TrimSuffix(file.Name(), ".toml")] = l
+	}
+
+	return locales, nil
+}
+
+// GetLocale returns the Locale for the given language. If there isn't
+// one, the English default is returned.
+func GetLocale(lang string) *Locale {
+	if l, ok := Locales[lang]; ok {
+		return l
+	}
+
+	return defaultLocale()
+}
+
+// pageLocale returns the Locale for the page that will be written to
+// the given file.
+func pageLocale(file string) *Locale {
+	return GetLocale(pageLanguage(file))
+}
+
+// T returns the translation of the given user interface string. If
+// there isn't one, the string itself is returned. If args are given,
+// the translation is used as a format for them. It's available in all
+// templates as the T function:
+//
+//      <a href="{{.Url}}">{{T "Read more"}}</a>
+//      {{T "%d minute read" .ReadingTime}}
+func (l *Locale) T(key string, args ...interface{}) string {
+	s, ok := l.Strings[key]
+	if !ok {
+		s = key
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(s, args...)
+	}
+
+	return s
+}
+
+// Format formats the given time like time.Format does but uses the
+// names of the months and days in this Locale.
+func (l *Locale) Format(t time.Time, layout string) string {
+	names := []struct {
+		token string
+		value string
+	}{
+		{"January", l.Months[t.Month()-1]},
+		{"Jan", short(l.ShortMonths, l.Months, int(t.Month())-1)},
+		{"Monday", l.Days[t.Weekday()]},
+		{"Mon", short(l.ShortDays, l.Days, int(t.Weekday()))},
+	}
+
+	result := ""
+	rest := ""
+
+outer:
+	for len(layout) > 0 {
+		for _, n := range names {
+			if strings.HasPrefix(layout, n.token) {
+				result += t.Format(rest) + n.value
+				rest = ""
+				layout = layout[len(n.token):]
+				continue outer
+			}
+		}
+
+		rest += layout[:1]
+		layout = layout[1:]
+	}
+
+	return result + t.Format(rest)
+}
+
+// short is a helper function for Format that returns the abbreviated
+// name at i. If there are no abbreviations, the first three letters of
+// the name are used.
+func short(abbrs, names []string, i int) string {
+	if len(abbrs) > i {
+		return abbrs[i]
+	}
+
+	r := []rune(names[i])
+	if len(r) > 3 {
+		r = r[:3]
+	}
+
+	return string(r)
+}
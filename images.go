@@ -0,0 +1,410 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"github.com/rwcarlsen/goexif/exif"
+	"golang.org/x/image/draw"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// imageQuality is the JPEG quality used when encoding images.
+const imageQuality = 85
+
+// Images contains all of the images processed for this site. Blog
+// entries use it to add srcset attributes to their images.
+var Images = ImageSet{}
+
+// Image is a processed image and all of its resized variants.
+type Image struct {
+	// Url is the url of the original image relative to the site.
+	Url string
+
+	// Width is the width of the original image in pixels.
+	Width int
+
+	// Height is the height of the original image in pixels.
+	Height int
+
+	// Variants is the list of resized versions of the image, smallest
+	// first.
+	Variants []*ImageVariant
+}
+
+// ImageVariant is a resized version of an Image.
+type ImageVariant struct {
+	// Url is the url of the variant relative to the site.
+	Url string
+
+	// Width is the width of the variant in pixels.
+	Width int
+
+	// Height is the height of the variant in pixels.
+	Height int
+}
+
+// Srcset returns the value for an <img srcset> attribute for this
+// image. The urls are relative to the directory of the given src.
+func (i *Image) Srcset(src string) string {
+	dir := src[:strings.LastIndex(src, "/")+1]
+
+	parts := []string{}
+	for _, v := range i.Variants {
+		parts = append(parts, fmt.Sprintf("%s%s %dw", dir,
+			path.Base(v.Url), v.Width))
+	}
+	parts = append(parts, fmt.Sprintf("%s %dw", src, i.Width))
+
+	return strings.Join(parts, ", ")
+}
+
+// ImageSet is a map of images keyed by their url relative to the
+// site.
+type ImageSet map[string]*Image
+
+// ParseImageWidths converts a comma separated list of widths into a
+// sorted list of ints.
+func ParseImageWidths(s string) ([]int, error) {
+	widths := []int{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		w, err := strconv.Atoi(part)
+		if err != nil || w <= 0 {
+			return nil, fmt.Errorf("invalid image width: %s", part)
+		}
+
+		widths = append(widths, w)
+	}
+
+	sort.Ints(widths)
+
+	return widths, nil
+}
+
+// Process looks through src recursively for JPEG and PNG images. Each
+// one is written to the same place in dest without its EXIF data and
+// along with a variant for each of the ImageWidths smaller than the
+// original. The variants are named after the original with the width
+// appended (e.g. photo-480w.jpg). Results are kept in CacheDir so
+// unchanged images aren't processed again on the next build. The
+// given prefix is prepended to the url of each image.
+func (is ImageSet) Process(dest, src, prefix string) error {
+	files, err := ioutil.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		s := path.Join(src, file.Name())
+		d := path.Join(dest, file.Name())
+
+		if file.IsDir() {
+			err = is.Process(d, s, prefix+file.Name()+"/")
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		if imageFormat(file.Name()) == "" {
+			continue
+		}
+
+		img, err := processImage(d, s, prefix+file.Name())
+		if err != nil {
+			return fmt.Errorf("processing image %s: %v", s, err)
+		}
+
+		is[img.Url] = img
+	}
+
+	return nil
+}
+
+// imgRegex matches the <img> tags generated from markdown.
+var imgRegex = regexp.MustCompile(`<img ([^>]*?)\s*/?>`)
+
+// srcRegex matches the src attribute of an <img> tag.
+var srcRegex = regexp.MustCompile(`src="([^"]*)"`)
+
+// Rewrite adds srcset, width and height attributes to all of the <img>
+// tags in the given HTML that refer to images in this set. Relative
+// urls are relative to the given directory within the site.
+func (is ImageSet) Rewrite(content, dir string) string {
+	return imgRegex.ReplaceAllStringFunc(content, func(tag string) string {
+		attrs := imgRegex.FindStringSubmatch(tag)[1]
+		if strings.Contains(attrs, "srcset=") {
+			return tag
+		}
+
+		m := srcRegex.FindStringSubmatch(attrs)
+		if m == nil {
+			return tag
+		}
+
+		src := m[1]
+		if isRelativeLink(src) {
+			src = path.Join(dir, src)
+		}
+
+		img, ok := is[path.Clean(strings.TrimPrefix(src, "/"))]
+		if !ok {
+			return tag
+		}
+
+		// Images smaller than all of the ImageWidths don't have
+		// variants, but they still get their size.
+		extra := []string{}
+		if len(img.Variants) > 0 {
+			extra = append(extra, fmt.Sprintf(`srcset="%s"`,
+				img.Srcset(m[1])))
+		}
+		if !strings.Contains(attrs, "width=") {
+			extra = append(extra, fmt.Sprintf(`width="%d" height="%d"`,
+				img.Width, img.Height))
+		}
+		if len(extra) == 0 {
+			return tag
+		}
+
+		return "<img " + attrs + " " + strings.Join(extra, " ") + " />"
+	})
+}
+
+// imageFormat returns the format of the image with the given file name
+// based on its extension or "" if it's not an image we process.
+func imageFormat(name string) string {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".jpg", ".jpeg":
+		return "jpeg"
+	case ".png":
+		return "png"
+	}
+
+	return ""
+}
+
+// processImage is a helper function for Process that handles a single
+// image.
+func processImage(dest, src, url string) (*Image, error) {
+	contents, err := ioutil.ReadFile(src)
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum(contents)
+	key := hex.EncodeToString(sum[:])
+	format := imageFormat(src)
+	orientation := exifOrientation(contents)
+
+	// Get the size without decoding the whole thing.
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(contents))
+	if err != nil {
+		return nil, err
+	}
+	if orientation >= 5 {
+		cfg.Width, cfg.Height = cfg.Height, cfg.Width
+	}
+
+	img := &Image{
+		Url:      url,
+		Width:    cfg.Width,
+		Height:   cfg.Height,
+		Variants: []*ImageVariant{},
+	}
+
+	// The image is only decoded if something isn't in the cache.
+	var decoded image.Image
+	decode := func() (image.Image, error) {
+		if decoded == nil {
+			d, _, err := image.Decode(bytes.NewReader(contents))
+			if err != nil {
+				return nil, err
+			}
+			decoded = orient(d, orientation)
+		}
+		return decoded, nil
+	}
+
+	// Write the original without the EXIF data.
+	err = cachedImage(dest, key+"-orig."+format, decode)
+	if err != nil {
+		return nil, err
+	}
+
+	// Make each of the variants.
+	base := strings.TrimSuffix(dest, path.Ext(dest))
+	for _, w := range ImageWidths {
+		if w >= img.Width {
+			break
+		}
+
+		h := img.Height * w / img.Width
+		vdest := fmt.Sprintf("%s-%dw%s", base, w, path.Ext(dest))
+
+		err = cachedImage(vdest, fmt.Sprintf("%s-%d.%s", key, w, format),
+			func() (image.Image, error) {
+				d, err := decode()
+				if err != nil {
+					return nil, err
+				}
+
+				r := image.NewRGBA(image.Rect(0, 0, w, h))
+				draw.CatmullRom.Scale(r, r.Bounds(), d, d.Bounds(),
+					draw.Over, nil)
+				return r, nil
+			})
+		if err != nil {
+			return nil, err
+		}
+
+		img.Variants = append(img.Variants, &ImageVariant{
+			Url:    path.Join(path.Dir(url), path.Base(vdest)),
+			Width:  w,
+			Height: h,
+		})
+	}
+
+	return img, nil
+}
+
+// cachedImage writes the image with the given cache name to dest. If
+// it's not in the CacheDir yet, create is called to create it and the
+// results are saved to the CacheDir.
+func cachedImage(dest, name string,
+	create func() (image.Image, error)) error {
+
+	cached := path.Join(CacheDir, "images", name)
+	if _, err := os.Stat(cached); err == nil {
+		return CopyFile(dest, cached)
+	}
+
+	img, err := create()
+	if err != nil {
+		return err
+	}
+
+	buf := new(bytes.Buffer)
+	if imageFormat(name) == "png" {
+		err = png.Encode(buf, img)
+	} else {
+		err = jpeg.Encode(buf, img, &jpeg.Options{Quality: imageQuality})
+	}
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(path.Dir(cached), 0750)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(cached, buf.Bytes(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(dest, buf.Bytes(), 0644)
+}
+
+// cover scales the given image so that it covers an image of the given
+// size and crops whatever doesn't fit from its center.
+func cover(img image.Image, w, h int) *image.RGBA {
+	b := img.Bounds()
+	src := b
+	if b.Dx()*h > b.Dy()*w {
+		cw := b.Dy() * w / h
+		src.Min.X += (b.Dx() - cw) / 2
+		src.Max.X = src.Min.X + cw
+	} else {
+		ch := b.Dx() * h / w
+		src.Min.Y += (b.Dy() - ch) / 2
+		src.Max.Y = src.Min.Y + ch
+	}
+
+	r := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(r, r.Bounds(), img, src, draw.Src, nil)
+
+	return r
+}
+
+// exifOrientation returns the EXIF orientation of the given image or
+// 1 if it doesn't have one.
+func exifOrientation(contents []byte) int {
+	x, err := exif.Decode(bytes.NewReader(contents))
+	if err != nil {
+		return 1
+	}
+
+	tag, err := x.Get(exif.Orientation)
+	if err != nil {
+		return 1
+	}
+
+	o, err := tag.Int(0)
+	if err != nil || o < 1 || o > 8 {
+		return 1
+	}
+
+	return o
+}
+
+// orient rotates and flips the given image so that it's upright
+// according to the given EXIF orientation. Because the EXIF data is
+// removed, this has to be done before the image is written.
+func orient(img image.Image, o int) image.Image {
+	if o <= 1 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if o >= 5 {
+		w, h = h, w
+	}
+
+	r := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			var dx, dy int
+			switch o {
+			case 2:
+				dx, dy = b.Dx()-1-x, y
+			case 3:
+				dx, dy = b.Dx()-1-x, b.Dy()-1-y
+			case 4:
+				dx, dy = x, b.Dy()-1-y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = b.Dy()-1-y, x
+			case 7:
+				dx, dy = b.Dy()-1-y, b.Dx()-1-x
+			case 8:
+				dx, dy = y, b.Dx()-1-x
+			}
+			r.Set(dx, dy, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+
+	return r
+}
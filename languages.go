@@ -0,0 +1,280 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Alternate is a version of a page in another language. It's used for
+// the <link rel="alternate" hreflang="..."> tags of a page.
+type Alternate struct {
+	// Language is the language of the page.
+	Language string
+
+	// Url is the url of the page. If the site's URL is known, it's
+	// absolute. Otherwise, it's relative to the current page.
+	Url string
+}
+
+// DefaultLanguage returns the language of entries that don't have one
+// in their file name. It's the first of the SiteLanguages or "" if the
+// site isn't multilingual.
+func DefaultLanguage() string {
+	if len(SiteLanguages) == 0 {
+		return ""
+	}
+
+	return SiteLanguages[0]
+}
+
+// IsSiteLanguage returns true if the given language is one of the
+// SiteLanguages.
+func IsSiteLanguage(lang string) bool {
+	for _, l := range SiteLanguages {
+		if l == lang {
+			return true
+		}
+	}
+
+	return false
+}
+
+// LanguageDir returns the directory within the output directory where
+// the pages for the given language go. The default language goes in
+// the output directory itself, so "" is returned for it.
+func LanguageDir(lang string) string {
+	if lang == DefaultLanguage() {
+		return ""
+	}
+
+	return lang
+}
+
+// Languages returns all of the languages the site is generated in. If
+// the site isn't multilingual, this is just "".
+func Languages() []string {
+	if len(SiteLanguages) == 0 {
+		return []string{""}
+	}
+
+	return SiteLanguages
+}
+
+// splitLanguage removes the .md extension from the given file name and
+// the language before it if it's one of the SiteLanguages (e.g.
+// post.de.md). It returns the remaining name and the language, which
+// is the DefaultLanguage if there wasn't one.
+func splitLanguage(file string) (string, string) {
+	name := strings.TrimSuffix(file, ".md")
+
+	ext := path.Ext(name)
+	if ext != "" && IsSiteLanguage(ext[1:]) {
+		return strings.TrimSuffix(name, ext), ext[1:]
+	}
+
+	return name, DefaultLanguage()
+}
+
+// GroupTranslations combines the entries with the same url into a
+// single entry. The version in the DefaultLanguage (or the first one
+// found if there isn't one) is returned and all of the versions are
+// linked to each other through their Translations. If the site isn't
+// multilingual, the entries are returned as is.
+func GroupTranslations(entries []*Entry) []*Entry {
+	if len(SiteLanguages) == 0 {
+		return entries
+	}
+
+	groups := map[string][]*Entry{}
+	names := []string{}
+
+	for _, e := range entries {
+		if _, ok := groups[e.Url]; !ok {
+			names = append(names, e.Url)
+		}
+		groups[e.Url] = append(groups[e.Url], e)
+	}
+
+	result := []*Entry{}
+	for _, name := range names {
+		versions := sortByLanguage(groups[name])
+
+		for _, e := range versions {
+			e.Translations = []*Entry{}
+			for _, t := range versions {
+				if t != e {
+					e.Translations = append(e.Translations, t)
+				}
+			}
+		}
+
+		result = append(result, versions[0])
+	}
+
+	return result
+}
+
+// sortByLanguage orders the given versions of an entry in the same
+// order as the SiteLanguages.
+func sortByLanguage(versions []*Entry) []*Entry {
+	sorted := []*Entry{}
+	for _, lang := range Languages() {
+		for _, e := range versions {
+			if e.Language == lang {
+				sorted = append(sorted, e)
+			}
+		}
+	}
+
+	return sorted
+}
+
+// EntriesForLanguage returns the version of each of the given entries
+// in the given language. Entries that haven't been translated into it
+// are left out.
+func EntriesForLanguage(entries []*Entry, lang string) []*Entry {
+	result := []*Entry{}
+	for _, e := range entries {
+		for _, v := range e.Versions() {
+			if v.Language == lang {
+				result = append(result, v)
+				break
+			}
+		}
+	}
+
+	return result
+}
+
+// NewHelper creates the Helper for a page that will be written to the
+// given file.
+func NewHelper(file string) Helper {
+	parts := pageDirs(file)
+	h := Helper{
+		Root:         strings.Repeat("../", len(parts)),
+		LanguageRoot: strings.Repeat("../", len(parts)),
+	}
+
+	if LanguageDir(pageLanguage(file)) != "" {
+		h.LanguageRoot = strings.Repeat("../", len(parts)-1)
+	}
+
+	return h
+}
+
+// pageLanguage returns the language of the page that will be written
+// to the given file based on the directory it's in.
+func pageLanguage(file string) string {
+	parts := pageDirs(file)
+	if len(parts) > 0 && IsSiteLanguage(parts[0]) {
+		return parts[0]
+	}
+
+	return DefaultLanguage()
+}
+
+// pageDirs returns the directories between the OutputDir and the given
+// file.
+func pageDirs(file string) []string {
+	rel, err := filepath.Rel(OutputDir, filepath.Dir(file))
+	if err != nil || rel == "." {
+		return []string{}
+	}
+
+	return strings.Split(filepath.ToSlash(rel), "/")
+}
+
+// pageAlternates returns the versions of the page that will be written
+// to the given file in each of the SiteLanguages. It assumes the page
+// exists in all of them.
+func pageAlternates(file string) []*Alternate {
+	return languageAlternates(file, SiteLanguages)
+}
+
+// languageAlternates returns the versions of the page that will be
+// written to the given file in each of the given languages.
+func languageAlternates(file string, langs []string) []*Alternate {
+	if len(SiteLanguages) == 0 {
+		return nil
+	}
+
+	rel, err := filepath.Rel(OutputDir, file)
+	if err != nil {
+		return nil
+	}
+	rel = filepath.ToSlash(rel)
+
+	h := NewHelper(file)
+	base := strings.TrimPrefix(rel, LanguageDir(pageLanguage(file))+"/")
+
+	alts := []*Alternate{}
+	for _, lang := range langs {
+		alts = append(alts, &Alternate{
+			Language: lang,
+			Url:      alternateUrl(h.Root, path.Join(LanguageDir(lang), base)),
+		})
+	}
+
+	return alts
+}
+
+// versionLanguages returns the languages, in the order of the
+// SiteLanguages, that have a listed version of one of the given entries
+// for which keep returns true.
+func versionLanguages(entries []*Entry, keep func(*Entry) bool) []string {
+	seen := map[string]bool{}
+	for _, e := range entries {
+		for _, v := range e.Versions() {
+			if v.Listed() && keep(v) {
+				seen[v.Language] = true
+			}
+		}
+	}
+
+	langs := []string{}
+	for _, lang := range SiteLanguages {
+		if seen[lang] {
+			langs = append(langs, lang)
+		}
+	}
+
+	return langs
+}
+
+// entryAlternates returns all of the versions of the given entry that
+// haven't expired.
+func entryAlternates(root string, e *Entry) []*Alternate {
+	if len(SiteLanguages) == 0 {
+		return nil
+	}
+
+	alts := []*Alternate{}
+	for _, v := range sortByLanguage(e.Versions()) {
+		if v.Expired() {
+			continue
+		}
+
+		alts = append(alts, &Alternate{
+			Language: v.Language,
+			Url:      alternateUrl(root, v.SiteUrl()),
+		})
+	}
+
+	return alts
+}
+
+// alternateUrl makes the url of an Alternate from the url of a page
+// relative to the site. It's absolute if we know the site's URL.
+// Otherwise, it's relative to the root given.
+func alternateUrl(root, url string) string {
+	if URL != "" {
+		return strings.TrimSuffix(URL, "/") + "/" + url
+	}
+
+	return root + url
+}
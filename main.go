@@ -9,6 +9,8 @@ import (
 	flag "github.com/ogier/pflag"
 	"os"
 	"path"
+	"strings"
+	"time"
 )
 
 const (
@@ -19,6 +21,22 @@ const (
 // application.
 var Version bool
 
+// imageWidths is the unparsed value of the ImageWidths flag.
+var imageWidths string
+
+// thumbnailSize is the unparsed value of the ThumbnailWidth and
+// ThumbnailHeight flag.
+var thumbnailSize string
+
+// timeZone is the unparsed value of the Location flag.
+var timeZone string
+
+// siteLanguages is the unparsed value of the SiteLanguages flag.
+var siteLanguages string
+
+// taxonomies is the unparsed value of the Taxonomies flag.
+var taxonomies string
+
 // WorkingDir is the directory where that should be prepended to all
 // the other configurable directories.
 var WorkingDir string
@@ -40,6 +58,34 @@ var BlogDir string
 // StaticDir is the directory where static assests can be found.
 var StaticDir string
 
+// GalleryDir is the directory where the photo galleries can be found.
+// Each directory in it is a gallery.
+var GalleryDir string
+
+// DataDir is the directory where the data files for the templates can
+// be found.
+var DataDir string
+
+// I18nDir is the directory where the translations of the user
+// interface can be found.
+var I18nDir string
+
+// AuthorsFile is the file where the profiles of the authors can be
+// found.
+var AuthorsFile string
+
+// TagsFile is the file where the descriptions and aliases of the tags
+// can be found.
+var TagsFile string
+
+// SectionsFile is the file where the content sections besides the
+// blog can be found.
+var SectionsFile string
+
+// CacheDir is the directory where results from previous builds are
+// kept so they don't need to be generated again.
+var CacheDir string
+
 // URL is the url for this site. The RSS feed will use it to generate links.
 var URL string
 
@@ -47,6 +93,39 @@ var URL string
 // index page.
 var MaxIndexEntries int
 
+// IndexSort is the order of the entries on the index page. See
+// SortEntries for the orders.
+var IndexSort string
+
+// SummaryWords is the number of words to use for an entry's summary
+// when it doesn't contain a <!--more--> marker.
+var SummaryWords int
+
+// WordsPerMinute is the reading speed used to estimate how long it
+// takes to read an entry.
+var WordsPerMinute int
+
+// Minify is a flag that determines whether or not the CSS, JavaScript
+// and HTML should be minified.
+var Minify bool
+
+// SiteLanguages are the languages the site is written in. The first
+// one is the default. If there are none, the site isn't multilingual.
+var SiteLanguages []string
+
+// Taxonomies are the names of the ways entries are classified besides
+// their tags (e.g. categories). The terms of each one are read from the
+// meta data with the same name (e.g. Categories).
+var Taxonomies []string
+
+// Location is the time zone of the site. Dates and times without a
+// time zone are in it and all dates are displayed in it.
+var Location = time.UTC
+
+// ImageWidths are the widths of the resized variants that are made
+// for each image.
+var ImageWidths []int
+
 func init() {
 	flag.BoolVarP(&Version, "version", "v", false,
 		"Output the current version of the application.")
@@ -70,6 +149,33 @@ func init() {
 	flag.StringVarP(&StaticDir, "static-dir", "s", "static",
 		"The directory where the static assets are located.")
 
+	flag.StringVar(&GalleryDir, "gallery-dir", "galleries",
+		"The directory where the photo galleries are located. Each "+
+			"directory in it is a gallery.")
+
+	flag.StringVar(&DataDir, "data-dir", "data",
+		"The directory where the JSON, YAML, TOML and CSV files for the "+
+			"templates are located.")
+
+	flag.StringVar(&I18nDir, "i18n-dir", "i18n",
+		"The directory where the translations of the user interface "+
+			"are located.")
+
+	flag.StringVar(&AuthorsFile, "authors-file", "authors.toml",
+		"The file where the profiles of the authors are located.")
+
+	flag.StringVar(&TagsFile, "tags-file", "tags.toml",
+		"The file where the descriptions and aliases of the tags are "+
+			"located.")
+
+	flag.StringVar(&SectionsFile, "sections-file", "sections.toml",
+		"The file where the content sections besides the blog are "+
+			"located.")
+
+	flag.StringVar(&CacheDir, "cache-dir", ".goblog-cache",
+		"The directory where processed images and other results are "+
+			"kept between builds.")
+
 	flag.StringVarP(&URL, "url", "u", "",
 		"The url to be prepended to link in the RSS feed. Defaults to "+
 			"the value in the channel <link>.")
@@ -77,6 +183,45 @@ func init() {
 	flag.IntVarP(&MaxIndexEntries, "index-entries", "i", 3,
 		"The maximum number of entries to display on the index page.")
 
+	flag.StringVar(&IndexSort, "index-sort", "created",
+		"The order of the entries on the index page: created, updated, "+
+			"title or weight. Pinned entries are always first.")
+
+	flag.IntVar(&SummaryWords, "summary-words", 70,
+		"The number of words to use for an entry's summary when it "+
+			"doesn't contain a <!--more--> marker. 0 uses the whole entry.")
+
+	flag.IntVar(&WordsPerMinute, "words-per-minute", 200,
+		"The reading speed used to estimate an entry's reading time.")
+
+	flag.BoolVarP(&Minify, "minify", "m", false,
+		"Minify the CSS, JavaScript and HTML.")
+
+	flag.StringVar(&timeZone, "timezone", "UTC",
+		"The time zone of the site (e.g. America/Denver). Dates without "+
+			"a time zone are in it. Use Local for the system's time zone.")
+
+	flag.StringVarP(&siteLanguages, "languages", "l", "",
+		"A comma separated list of the languages the site is written "+
+			"in (e.g. en,de). The first one is the default.")
+
+	flag.StringVar(&taxonomies, "taxonomies", "",
+		"A comma separated list of the ways entries are classified "+
+			"besides tags (e.g. categories,topics).")
+
+	flag.StringVar(&imageWidths, "image-widths", "480,960,1920",
+		"A comma separated list of widths to resize images to. An empty "+
+			"list disables resizing.")
+
+	flag.StringVar(&thumbnailSize, "thumbnail-size", "480x270",
+		"The size of the thumbnails of the featured images of the "+
+			"entries (e.g. 480x270).")
+
+	flag.StringVar(&CardBackground, "card-background", "#1d2731",
+		"The background of the social cards of the entries. Either a "+
+			"color (e.g. #1d2731) or the path of an image in the static "+
+			"dir.")
+
 }
 
 func main() {
@@ -89,11 +234,102 @@ func main() {
 		return
 	}
 
+	// Figure out what we were asked to do. Without a command, we just
+	// build the site.
+	command := flag.Arg(0)
+	if command != "" && command != "check" {
+		fmt.Println("unknown command:", command)
+		os.Exit(1)
+	}
+
 	// Setup the directories.
 	OutputDir = path.Join(WorkingDir, OutputDir)
 	TemplateDir = path.Join(WorkingDir, TemplateDir)
 	StaticDir = path.Join(WorkingDir, StaticDir)
 	BlogDir = path.Join(WorkingDir, BlogDir)
+	CacheDir = path.Join(WorkingDir, CacheDir)
+	GalleryDir = path.Join(WorkingDir, GalleryDir)
+	DataDir = path.Join(WorkingDir, DataDir)
+	I18nDir = path.Join(WorkingDir, I18nDir)
+	AuthorsFile = path.Join(WorkingDir, AuthorsFile)
+	TagsFile = path.Join(WorkingDir, TagsFile)
+	SectionsFile = path.Join(WorkingDir, SectionsFile)
+
+	// Get the time zone.
+	var err error
+	Location, err = time.LoadLocation(timeZone)
+	if err != nil {
+		fmt.Println("loading time zone:", err)
+		os.Exit(1)
+	}
+
+	// Get the list of languages.
+	SiteLanguages = []string{}
+	for _, lang := range strings.Split(siteLanguages, ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			SiteLanguages = append(SiteLanguages, lang)
+		}
+	}
+
+	// Make sure we know how to sort the index.
+	_, err = SortEntries(nil, IndexSort)
+	if err != nil {
+		fmt.Println("parsing index sort:", err)
+		os.Exit(1)
+	}
+
+	// Get the list of taxonomies.
+	Taxonomies = []string{}
+	for _, taxonomy := range strings.Split(taxonomies, ",") {
+		taxonomy = strings.TrimSpace(taxonomy)
+		if taxonomy == "" {
+			continue
+		}
+
+		slug, _ := MakeBlogName(taxonomy)
+		if taxonomy == "tags" || slug != taxonomy {
+			fmt.Println("invalid taxonomy:", taxonomy)
+			os.Exit(1)
+		}
+
+		Taxonomies = append(Taxonomies, taxonomy)
+	}
+
+	// Get the list of image widths.
+	ImageWidths, err = ParseImageWidths(imageWidths)
+	if err != nil {
+		fmt.Println("parsing image widths:", err)
+		os.Exit(1)
+	}
+
+	// Get the size of the thumbnails.
+	ThumbnailWidth, ThumbnailHeight, err = ParseThumbnailSize(thumbnailSize)
+	if err != nil {
+		fmt.Println("parsing thumbnail size:", err)
+		os.Exit(1)
+	}
+
+	// Load the content sections. They need to be known before the
+	// templates because they may have templates of their own.
+	sections, err := LoadSections(SectionsFile)
+	if err != nil {
+		fmt.Println("loading sections:", err)
+		os.Exit(1)
+	}
+	Sections = []*Section{BlogSection()}
+	for _, s := range sections {
+		s.Dir = path.Join(WorkingDir, s.Dir)
+		Sections = append(Sections, s)
+	}
+
+	// The taxonomies can't use the names the site already uses.
+	for _, taxonomy := range Taxonomies {
+		err = CheckTaxonomy(taxonomy)
+		if err != nil {
+			fmt.Println("checking taxonomies:", err)
+			os.Exit(1)
+		}
+	}
 
 	// First load the templates.
 	tmplts, err := LoadTemplates(TemplateDir)
@@ -102,6 +338,55 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Make sure we can use the background of the social cards.
+	_, err = cardBackground()
+	if err != nil {
+		fmt.Println("loading card background:", err)
+		os.Exit(1)
+	}
+
+	// Load the data files for the templates.
+	Data, err = LoadData(DataDir)
+	if err != nil {
+		fmt.Println("loading data:", err)
+		os.Exit(1)
+	}
+
+	// Load what the site says about itself.
+	Site, err = LoadSiteInfo(path.Join(TemplateDir, "channel.rss"))
+	if err != nil {
+		fmt.Println("loading channel.rss:", err)
+		os.Exit(1)
+	}
+
+	// Load the shortcodes.
+	Shortcodes, err = LoadShortcodes(path.Join(TemplateDir, "shortcodes"))
+	if err != nil {
+		fmt.Println("loading shortcodes:", err)
+		os.Exit(1)
+	}
+
+	// Load the translations.
+	Locales, err = LoadLocales(I18nDir)
+	if err != nil {
+		fmt.Println("loading translations:", err)
+		os.Exit(1)
+	}
+
+	// Load the author profiles.
+	AuthorProfiles, err = LoadAuthors(AuthorsFile)
+	if err != nil {
+		fmt.Println("loading authors:", err)
+		os.Exit(1)
+	}
+
+	// Load the tag profiles.
+	TagProfiles, err = LoadTags(TagsFile)
+	if err != nil {
+		fmt.Println("loading tags:", err)
+		os.Exit(1)
+	}
+
 	// Next, let's clear out the OutputDir if requested.
 	if EmptyOutputDir {
 		err = os.RemoveAll(OutputDir)
@@ -125,23 +410,172 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Strip and resize the images we just copied.
+	err = Images.Process(OutputDir, StaticDir, "")
+	if err != nil {
+		fmt.Println("processing images:", err)
+		os.Exit(1)
+	}
+
+	// Minify and fingerprint the CSS and JavaScript.
+	err = Assets.Process(OutputDir, StaticDir, "")
+	if err != nil {
+		fmt.Println("processing assets:", err)
+		os.Exit(1)
+	}
+
 	// Get a list of files from the BlogDir.
-	entries, err := GetBlogFiles(BlogDir)
+	entries, err := GetBlogFiles(Sections)
 	if err != nil {
 		fmt.Println("getting blog file list:", err)
 		os.Exit(1)
 	}
 
-	// Iteratively Parse each blog for it's useful data and generate a
-	// page for each blog.
+	// Read the entries up front so that each version knows about the
+	// others when their pages are generated and we know which ones have
+	// expired.
+	for _, blog := range entries {
+		for _, v := range blog.Versions() {
+			err = v.ReadInfo()
+			if err != nil {
+				fmt.Println("reading blog", v, ":", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Remove the files of the entries that have expired in case they
+	// are left from an earlier build. Anything they share with the
+	// entries that are still around is made again below.
+	for _, blog := range entries {
+		for _, v := range blog.Versions() {
+			if !v.Expired() {
+				continue
+			}
+
+			err = v.RemoveFiles(OutputDir)
+			if err != nil {
+				fmt.Println("removing files for", v.Path, ":", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Copy the assets of the page bundles that are still around.
 	for _, blog := range entries {
-		contents, err := blog.Parse()
+		if len(filterEntries(blog.Versions(), isCurrent)) == 0 {
+			continue
+		}
+
+		err = blog.CopyAssets(OutputDir)
+		if err != nil {
+			fmt.Println("copying assets for", blog.Path, ":", err)
+			os.Exit(1)
+		}
+
+		// Make the thumbnails of the featured images.
+		for _, v := range filterEntries(blog.Versions(), isCurrent) {
+			err = v.MakeThumbnail(OutputDir)
+			if err != nil {
+				fmt.Println("making thumbnail for", v.Path, ":", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Parse each version once now that the images of the page bundles
+	// are known. Every language uses the same HTML for them.
+	for _, blog := range entries {
+		for _, v := range blog.Versions() {
+			_, err = v.Parse()
+			if err != nil {
+				fmt.Println("parsing blog", v, ":", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Find the photo galleries and copy their photos.
+	Galleries, err = GetGalleries(GalleryDir)
+	if err != nil {
+		fmt.Println("getting galleries:", err)
+		os.Exit(1)
+	}
+
+	for _, g := range Galleries {
+		err = g.CopyPhotos(OutputDir)
+		if err != nil {
+			fmt.Println("copying photos for", g.Name, ":", err)
+			os.Exit(1)
+		}
+	}
+
+	// Generate the site in each language.
+	for _, lang := range Languages() {
+		dir := path.Join(OutputDir, LanguageDir(lang))
+		err = MakeDirIfNotExists(dir)
+		if err != nil {
+			fmt.Println("making output dir:", err)
+			os.Exit(1)
+		}
+
+		ltmplts, err := tmplts.ForLanguage(lang)
+		if err != nil {
+			fmt.Println("loading templates for", lang, ":", err)
+			os.Exit(1)
+		}
+
+		makeSite(ltmplts, dir, EntriesForLanguage(entries, lang))
+	}
+
+	// Check the site we just built if requested.
+	if command == "check" {
+		problems, err := Check(OutputDir, URL)
 		if err != nil {
-			fmt.Println("parsing blog", blog, ":", err)
+			fmt.Println("checking output dir:", err)
+			os.Exit(1)
+		}
+
+		for _, p := range problems {
+			fmt.Println(p)
+		}
+
+		if len(problems) > 0 {
+			fmt.Println(len(problems), "problems found")
 			os.Exit(1)
 		}
+	}
+}
+
+// makeSite generates all of the pages for the given entries in the
+// given directory. The entries should already be parsed.
+func makeSite(tmplts Templates, dir string, entries []*Entry) {
+	var err error
+
+	// Everything but their pages only uses the entries that should be
+	// listed.
+	all := entries
+	entries = filterEntries(entries, (*Entry).Listed)
+
+	// Group the entries into their series so each one knows where it
+	// is in its series.
+	series, err := GetSeries(entries)
+	if err != nil {
+		fmt.Println("getting series:", err)
+		os.Exit(1)
+	}
 
-		err = tmplts.MakeEntry(OutputDir, blog, contents)
+	// Generate a page for each blog. The ones that have expired are
+	// replaced by a page saying they are gone.
+	for _, blog := range all {
+		if blog.Expired() {
+			err = tmplts.MakeGone(dir, blog)
+		} else {
+			err = tmplts.MakeEntry(dir, blog, blog.content)
+			if err == nil && blog.Image == "" {
+				err = MakeCard(dir, blog)
+			}
+		}
 		if err != nil {
 			fmt.Println("generating blog html", blog, ":", err)
 			os.Exit(1)
@@ -149,47 +583,213 @@ func main() {
 	}
 
 	// Generate the about page.
-	err = tmplts.MakeAbout(OutputDir)
+	err = tmplts.MakeAbout(dir)
 	if err != nil {
 		fmt.Println("generating about.html:", err)
 		os.Exit(1)
 	}
 
-	// Generate the tags page.
-	tags := GetTags(entries)
-	err = tmplts.MakeTags(OutputDir, tags.Slice())
+	// Generate the tags page and the pages for the other taxonomies.
+	for _, taxonomy := range append([]string{"tags"}, Taxonomies...) {
+		makeTaxonomy(tmplts, dir, taxonomy, entries)
+	}
+
+	// Generate the author pages and their feeds if there is a template
+	// for them.
+	if _, ok := tmplts["author"]; ok {
+		for _, a := range GetAuthors(entries).Slice() {
+			err = tmplts.MakeAuthor(dir, a)
+			if err != nil {
+				fmt.Println("generating", a.Url(), ":", err)
+				os.Exit(1)
+			}
+
+			c := 10
+			if len(a.Entries) < c {
+				c = len(a.Entries)
+			}
+			err = MakeFeed(a.Entries[:c], URL, TemplateDir,
+				path.Join(dir, a.FeedUrl()))
+			if err != nil {
+				fmt.Println("generating", a.FeedUrl(), ":", err)
+			}
+		}
+	}
+
+	// Generate the series pages.
+	for _, s := range series {
+		err = tmplts.MakeSeries(dir, s)
+		if err != nil {
+			fmt.Println("generating", s.Url(), ":", err)
+			os.Exit(1)
+		}
+	}
+
+	// Generate the photo galleries.
+	err = tmplts.MakeGalleries(dir, Galleries)
 	if err != nil {
-		fmt.Println("generating tags.html:", err)
+		fmt.Println("generating galleries:", err)
 		os.Exit(1)
 	}
 
+	for _, g := range Galleries {
+		err = tmplts.MakeGallery(dir, g)
+		if err != nil {
+			fmt.Println("generating", g.Url(), ":", err)
+			os.Exit(1)
+		}
+
+		for _, p := range g.Photos {
+			err = tmplts.MakePhoto(dir, p)
+			if err != nil {
+				fmt.Println("generating", p.Url(), ":", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Generate the pages of the other sections.
+	for _, s := range Sections[1:] {
+		sbd := GetEntriesByDate(SectionEntries(entries, s))
+		list, err := SortEntries(sbd, s.Sort)
+		if err != nil {
+			fmt.Println("sorting", s.Name, ":", err)
+			os.Exit(1)
+		}
+
+		err = tmplts.MakeSection(dir, s, PinEntries(list))
+		if err != nil {
+			fmt.Println("generating", s.Url(), ":", err)
+			os.Exit(1)
+		}
+
+		err = tmplts.MakeSectionArchive(dir, s, GetArchives(sbd))
+		if err != nil {
+			fmt.Println("generating", s.ArchiveUrl(), ":", err)
+			os.Exit(1)
+		}
+	}
+
 	// Get a sort list of archives.
-	ebd := GetEntriesByDate(entries)
-	err = tmplts.MakeArchive(OutputDir, GetArchives(ebd))
+	ebd := GetEntriesByDate(IndexEntries(entries))
+	archives := GetArchives(ebd)
+	err = tmplts.MakeArchive(dir, archives)
 	if err != nil {
 		fmt.Println("generating archive.html:", err)
 		os.Exit(1)
 	}
 
+	// Generate the pages for each year and month.
+	for _, y := range archives {
+		err = tmplts.MakeArchiveYear(dir, y)
+		if err != nil {
+			fmt.Println("generating", y.Url(), ":", err)
+			os.Exit(1)
+		}
+
+		for _, m := range y.Months {
+			err = tmplts.MakeArchiveMonth(dir, m)
+			if err != nil {
+				fmt.Println("generating", m.Url(), ":", err)
+				os.Exit(1)
+			}
+		}
+	}
+
 	// Generate the index page.
+	index, err := SortEntries(ebd, IndexSort)
+	if err != nil {
+		fmt.Println("sorting index:", err)
+		os.Exit(1)
+	}
+	index = PinEntries(index)
 	c := MaxIndexEntries
-	if len(ebd) < c {
-		c = len(ebd)
+	if len(index) < c {
+		c = len(index)
 	}
-	err = tmplts.MakeIndex(OutputDir, ebd[:c])
+	err = tmplts.MakeIndex(dir, index[:c])
 	if err != nil {
 		fmt.Println("generating index.html:", err)
 		os.Exit(1)
 	}
 
+	// Generate the recently updated page and its feed if there is a
+	// template for it.
+	if _, ok := tmplts["updated"]; ok {
+		updated, _ := SortEntries(ebd, "updated")
+		c = 10
+		if len(updated) < c {
+			c = len(updated)
+		}
+
+		err = tmplts.MakeUpdated(dir, updated[:c])
+		if err != nil {
+			fmt.Println("generating updated.html:", err)
+			os.Exit(1)
+		}
+
+		err = MakeFeed(updated[:c], URL, TemplateDir,
+			path.Join(dir, "updated.rss"))
+		if err != nil {
+			fmt.Println("generating updated.rss:", err)
+		}
+	}
+
 	// Generate the RSS feed.
+	fbd := GetEntriesByDate(FeedEntries(entries))
 	c = 10
-	if len(ebd) < c {
-		c = len(ebd)
+	if len(fbd) < c {
+		c = len(fbd)
 	}
-	err = MakeRss(ebd[:c], URL, TemplateDir, OutputDir)
+	err = MakeRss(fbd[:c], URL, TemplateDir, dir)
 	if err != nil {
 		fmt.Println("generating feed.rss:", err)
 		fmt.Println("no rss will be available")
 	}
 }
+
+// makeTaxonomy generates the page listing the terms of the given
+// taxonomy and the page and feed of each term in the given directory.
+func makeTaxonomy(tmplts Templates, dir, taxonomy string, entries []*Entry) {
+	terms, err := GetTerms(entries, taxonomy)
+	if err != nil {
+		fmt.Println("getting", taxonomy, ":", err)
+		os.Exit(1)
+	}
+
+	err = tmplts.MakeTaxonomy(dir, taxonomy, terms.Slice())
+	if err != nil {
+		fmt.Println("generating", taxonomy+".html:", err)
+		os.Exit(1)
+	}
+
+	// The term pages and their feeds are only made if there is a
+	// template for them.
+	if _, ok := tmplts[taxonomy+"-term"]; !ok {
+		return
+	}
+
+	for _, term := range terms.Slice() {
+		term.Entries = GetEntriesByDate(term.Entries)
+		err = tmplts.MakeTerm(dir, term)
+		if err != nil {
+			fmt.Println("generating", term.Url(), ":", err)
+			os.Exit(1)
+		}
+
+		c := 10
+		if len(term.Entries) < c {
+			c = len(term.Entries)
+		}
+		err = MakeFeed(term.Entries[:c], URL, TemplateDir,
+			path.Join(dir, term.FeedUrl()))
+		if err != nil {
+			fmt.Println("generating", term.FeedUrl(), ":", err)
+		}
+	}
+}
+
+// isCurrent returns true if the given entry hasn't expired.
+func isCurrent(e *Entry) bool {
+	return !e.Expired()
+}
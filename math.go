@@ -0,0 +1,746 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// RenderMath replaces the LaTeX math in the given markdown with
+// MathML. Inline math is between single dollar signs ($x^2$) and
+// display math is between double ones ($$x^2$$). The MathML is kept in
+// the given placeholders so the markdown renderer doesn't touch it.
+// Math in code and HTML comments is left alone and \$ is a dollar sign.
+//
+// An opening $ must be followed by something other than a space and a
+// closing one must come right after something other than a space and
+// not be followed by a digit, so prices like $5 and $10 aren't math.
+// Inline math doesn't span paragraphs or code spans.
+func RenderMath(markdown string, ph *placeholders) string {
+	buf := new(strings.Builder)
+
+	// The code is copied as is.
+	last := 0
+	for _, r := range codeRanges(markdown) {
+		buf.WriteString(renderInlineMath(markdown[last:r[0]], ph))
+		buf.WriteString(markdown[r[0]:r[1]])
+		last = r[1]
+	}
+	buf.WriteString(renderInlineMath(markdown[last:], ph))
+
+	return buf.String()
+}
+
+// renderInlineMath is a helper function for RenderMath that replaces
+// the math in the given markdown, which has no code.
+func renderInlineMath(md string, ph *placeholders) string {
+	buf := new(strings.Builder)
+
+	for i := 0; i < len(md); {
+		switch {
+		case strings.HasPrefix(md[i:], "<!--"):
+			end := strings.Index(md[i:], "-->")
+			if end < 0 {
+				end = len(md) - i - 3
+			}
+			buf.WriteString(md[i : i+end+3])
+			i += end + 3
+
+		case strings.HasPrefix(md[i:], `\$`):
+			buf.WriteString("$")
+			i += 2
+
+		case strings.HasPrefix(md[i:], "$$"):
+			end := strings.Index(md[i+2:], "$$")
+			if end < 0 {
+				buf.WriteString("$$")
+				i += 2
+				continue
+			}
+			buf.WriteString(ph.add(MathML(md[i+2:i+2+end], true)))
+			i += 2 + end + 2
+
+		case md[i] == '$':
+			end := closingDollar(md[i+1:])
+			if end < 0 {
+				buf.WriteString("$")
+				i++
+				continue
+			}
+			buf.WriteString(ph.add(MathML(md[i+1:i+1+end], false)))
+			i += 1 + end + 1
+
+		default:
+			buf.WriteByte(md[i])
+			i++
+		}
+	}
+
+	return buf.String()
+}
+
+// closingDollar is a helper function for renderInlineMath that finds
+// the dollar sign that closes the inline math at the start of the given
+// text. It returns -1 if there isn't one.
+func closingDollar(s string) int {
+	if s == "" || s[0] == ' ' || s[0] == '\t' || s[0] == '\n' {
+		return -1
+	}
+
+	for j := 1; j < len(s); j++ {
+		switch {
+		case s[j] == '\\':
+			j++
+		case strings.HasPrefix(s[j:], "\n\n") || s[j] == '`':
+			return -1
+		case s[j] == '$':
+			if unicode.IsSpace(rune(s[j-1])) {
+				continue
+			}
+			if j+1 < len(s) && s[j+1] >= '0' && s[j+1] <= '9' {
+				continue
+			}
+			return j
+		}
+	}
+
+	return -1
+}
+
+// MathML converts the given LaTeX math to MathML. If display is true,
+// it's displayed as a block. The LaTeX is kept as an annotation. Only
+// the common parts of LaTeX are understood; anything else is shown as
+// an error.
+func MathML(tex string, display bool) string {
+	p := &mathParser{src: tex, tokens: tokenizeMath(tex), display: display}
+	nodes := p.parseExpr(nil)
+	for p.pos < len(p.tokens) {
+		// Skip whatever stopped us at the top level.
+		p.pos++
+		nodes = append(nodes, p.parseExpr(nil)...)
+	}
+
+	attrs := ""
+	if display {
+		attrs = ` display="block"`
+	}
+
+	return `<math xmlns="http://www.w3.org/1998/Math/MathML"` + attrs +
+		`><semantics>` + mrow(nodes) +
+		`<annotation encoding="application/x-tex">` +
+		html.EscapeString(strings.TrimSpace(tex)) +
+		`</annotation></semantics></math>`
+}
+
+// The kinds of mathToken.
+const (
+	mathCommand = iota
+	mathNumber
+	mathLetter
+	mathOperator
+	mathOpen
+	mathClose
+	mathSup
+	mathSub
+	mathAmp
+)
+
+// mathToken is a piece of LaTeX math.
+type mathToken struct {
+	kind int
+
+	// text is the token without the backslash of a command.
+	text string
+
+	// start and end are where the token is in the source.
+	start, end int
+}
+
+// tokenizeMath splits the given LaTeX math into tokens. Whitespace is
+// dropped.
+func tokenizeMath(tex string) []*mathToken {
+	tokens := []*mathToken{}
+
+	for i := 0; i < len(tex); {
+		r, size := utf8.DecodeRuneInString(tex[i:])
+		t := &mathToken{start: i, end: i + size, text: string(r)}
+
+		switch {
+		case unicode.IsSpace(r):
+			i += size
+			continue
+		case r == '\\':
+			j := i + 1
+			for j < len(tex) && isASCIILetter(tex[j]) {
+				j++
+			}
+			if j == i+1 && j < len(tex) {
+				_, size := utf8.DecodeRuneInString(tex[j:])
+				j += size
+			}
+			t.kind, t.text, t.end = mathCommand, tex[i+1:j], j
+		case r >= '0' && r <= '9' || r == '.' && i+1 < len(tex) &&
+			tex[i+1] >= '0' && tex[i+1] <= '9':
+			j := i + 1
+			for j < len(tex) && (tex[j] >= '0' && tex[j] <= '9' ||
+				tex[j] == '.') {
+				j++
+			}
+			t.kind, t.text, t.end = mathNumber, tex[i:j], j
+		case r == '{':
+			t.kind = mathOpen
+		case r == '}':
+			t.kind = mathClose
+		case r == '^':
+			t.kind = mathSup
+		case r == '_':
+			t.kind = mathSub
+		case r == '&':
+			t.kind = mathAmp
+		case unicode.IsLetter(r):
+			t.kind = mathLetter
+		default:
+			t.kind = mathOperator
+		}
+
+		tokens = append(tokens, t)
+		i = t.end
+	}
+
+	return tokens
+}
+
+// isASCIILetter returns true if the given byte is an ASCII letter.
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// mathParser turns the tokens of LaTeX math into MathML.
+type mathParser struct {
+	src     string
+	tokens  []*mathToken
+	pos     int
+	display bool
+}
+
+// peek returns the next token or nil if there aren't any more.
+func (p *mathParser) peek() *mathToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+
+	return p.tokens[p.pos]
+}
+
+// next returns the next token and moves past it.
+func (p *mathParser) next() *mathToken {
+	t := p.peek()
+	if t != nil {
+		p.pos++
+	}
+
+	return t
+}
+
+// isCommand returns true if the given token is the given command.
+func isCommand(t *mathToken, name string) bool {
+	return t != nil && t.kind == mathCommand && t.text == name
+}
+
+// parseExpr parses terms until the end, a closing brace or a token
+// for which stop returns true. None of those are consumed.
+func (p *mathParser) parseExpr(stop func(*mathToken) bool) []string {
+	nodes := []string{}
+
+	for {
+		t := p.peek()
+		if t == nil || t.kind == mathClose || stop != nil && stop(t) ||
+			t.kind == mathAmp || isCommand(t, "\\") ||
+			isCommand(t, "right") || isCommand(t, "end") {
+			return nodes
+		}
+
+		if node := p.parseTerm(); node != "" {
+			nodes = append(nodes, node)
+		}
+	}
+}
+
+// parseTerm parses an atom and its subscript and superscript.
+func (p *mathParser) parseTerm() string {
+	base, limits := p.parseAtom()
+
+	sub, sup := "", ""
+	for {
+		t := p.peek()
+		if t == nil || t.kind != mathSub && t.kind != mathSup {
+			break
+		}
+
+		p.next()
+		if t.kind == mathSub {
+			sub = p.parseArg()
+		} else {
+			sup = p.parseArg()
+		}
+	}
+
+	// Primes are superscripts too.
+	for t := p.peek(); t != nil && t.kind == mathOperator &&
+		t.text == "'"; t = p.peek() {
+		p.next()
+		sup += "<mo>′</mo>"
+	}
+	if strings.Count(sup, "<mo>′</mo>") > 1 {
+		sup = "<mrow>" + sup + "</mrow>"
+	}
+
+	if base == "" && (sub != "" || sup != "") {
+		base = "<mrow></mrow>"
+	}
+
+	under, over, both := "msub", "msup", "msubsup"
+	if limits && p.display {
+		under, over, both = "munder", "mover", "munderover"
+	}
+
+	switch {
+	case sub != "" && sup != "":
+		return "<" + both + ">" + base + sub + sup + "</" + both + ">"
+	case sub != "":
+		return "<" + under + ">" + base + sub + "</" + under + ">"
+	case sup != "":
+		return "<" + over + ">" + base + sup + "</" + over + ">"
+	}
+
+	return base
+}
+
+// parseArg parses the argument of a command or script, which is either
+// a group or a single token.
+func (p *mathParser) parseArg() string {
+	t := p.peek()
+	if t == nil {
+		return "<mrow></mrow>"
+	}
+
+	// Only the first digit of a number is the argument (e.g. \frac12).
+	if t.kind == mathNumber && len(t.text) > 1 {
+		rest := &mathToken{kind: mathNumber, text: t.text[1:],
+			start: t.start + 1, end: t.end}
+		t.text, t.end = t.text[:1], t.start+1
+		p.tokens = append(p.tokens[:p.pos+1],
+			append([]*mathToken{rest}, p.tokens[p.pos+1:]...)...)
+	}
+
+	node, _ := p.parseAtom()
+	if node == "" {
+		return "<mrow></mrow>"
+	}
+
+	return node
+}
+
+// parseGroup parses a group in braces and returns its nodes.
+func (p *mathParser) parseGroup() []string {
+	if t := p.peek(); t == nil || t.kind != mathOpen {
+		node := p.parseArg()
+		return []string{node}
+	}
+
+	p.next()
+	nodes := p.parseExpr(nil)
+	if t := p.peek(); t != nil && t.kind == mathClose {
+		p.next()
+	}
+
+	return nodes
+}
+
+// rawGroup returns the source of the group in braces that comes next
+// and moves past it.
+func (p *mathParser) rawGroup() string {
+	t := p.peek()
+	if t == nil {
+		return ""
+	}
+	if t.kind != mathOpen {
+		p.next()
+		return t.text
+	}
+
+	depth := 0
+	for i := p.pos; i < len(p.tokens); i++ {
+		switch p.tokens[i].kind {
+		case mathOpen:
+			depth++
+		case mathClose:
+			depth--
+			if depth == 0 {
+				raw := p.src[t.end:p.tokens[i].start]
+				p.pos = i + 1
+				return raw
+			}
+		}
+	}
+
+	// There is no closing brace, so take the rest.
+	p.pos = len(p.tokens)
+	return p.src[t.end:]
+}
+
+// parseAtom parses a single piece of math. It also returns whether
+// any scripts should be placed above and below it in display math.
+func (p *mathParser) parseAtom() (string, bool) {
+	t := p.next()
+	if t == nil {
+		return "", false
+	}
+
+	switch t.kind {
+	case mathNumber:
+		return "<mn>" + t.text + "</mn>", false
+	case mathLetter:
+		return "<mi>" + html.EscapeString(t.text) + "</mi>", false
+	case mathOperator:
+		return mathOperatorNode(t.text), false
+	case mathOpen:
+		p.pos--
+		return mrow(p.parseGroup()), false
+	case mathSup, mathSub:
+		// A script without a base.
+		p.pos--
+		return "", false
+	case mathClose, mathAmp:
+		return "", false
+	}
+
+	return p.parseCommand(t)
+}
+
+// parseCommand parses a command and its arguments.
+func (p *mathParser) parseCommand(t *mathToken) (string, bool) {
+	name := t.text
+
+	if s, ok := mathIdentifiers[name]; ok {
+		if len([]rune(s)) == 1 && unicode.IsUpper([]rune(s)[0]) {
+			return `<mi mathvariant="normal">` + s + "</mi>", false
+		}
+		return "<mi>" + s + "</mi>", false
+	}
+	if s, ok := mathOperators[name]; ok {
+		return "<mo>" + html.EscapeString(s) + "</mo>", false
+	}
+	if s, ok := mathLargeOperators[name]; ok {
+		return "<mo>" + s + "</mo>", name != "int" && name != "iint" &&
+			name != "iiint" && name != "oint"
+	}
+	if _, ok := mathFunctions[name]; ok {
+		return "<mi>" + name + "</mi>", mathFunctions[name]
+	}
+	if s, ok := mathAccents[name]; ok {
+		arg := p.parseArg()
+		stretchy := "false"
+		if name == "overline" || name == "widehat" || name == "widetilde" ||
+			name == "overrightarrow" {
+			stretchy = "true"
+		}
+		return `<mover accent="true">` + arg + `<mo stretchy="` +
+			stretchy + `">` + s + "</mo></mover>", false
+	}
+	if s, ok := mathSpaces[name]; ok {
+		return `<mspace width="` + s + `"></mspace>`, false
+	}
+	if v, ok := mathVariants[name]; ok {
+		raw := p.rawGroup()
+		if name == "operatorname" {
+			return "<mi>" + html.EscapeString(raw) + "</mi>", false
+		}
+		if v == "text" {
+			return "<mtext>" + html.EscapeString(raw) + "</mtext>", false
+		}
+
+		sub := &mathParser{src: raw, tokens: tokenizeMath(raw),
+			display: p.display}
+		nodes := sub.parseExpr(nil)
+		for i, node := range nodes {
+			if strings.HasPrefix(node, "<mi>") {
+				nodes[i] = `<mi mathvariant="` + v + `">` + node[4:]
+			}
+		}
+		return mrow(nodes), false
+	}
+
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac":
+		num := p.parseArg()
+		den := p.parseArg()
+		return "<mfrac>" + num + den + "</mfrac>", false
+
+	case "binom":
+		top := p.parseArg()
+		bottom := p.parseArg()
+		return `<mrow><mo>(</mo><mfrac linethickness="0">` + top +
+			bottom + "</mfrac><mo>)</mo></mrow>", false
+
+	case "sqrt":
+		index := ""
+		if t := p.peek(); t != nil && t.kind == mathOperator &&
+			t.text == "[" {
+			p.next()
+			index = mrow(p.parseExpr(func(t *mathToken) bool {
+				return t.kind == mathOperator && t.text == "]"
+			}))
+			p.next()
+		}
+		arg := p.parseArg()
+		if index != "" {
+			return "<mroot>" + arg + index + "</mroot>", false
+		}
+		return "<msqrt>" + arg + "</msqrt>", false
+
+	case "left":
+		open := p.delimiter()
+		inner := p.parseExpr(nil)
+		close := ""
+		if isCommand(p.peek(), "right") {
+			p.next()
+			close = p.delimiter()
+		}
+		return "<mrow>" + fence(open) + strings.Join(inner, "") +
+			fence(close) + "</mrow>", false
+
+	case "big", "Big", "bigg", "Bigg", "bigl", "bigr", "Bigl", "Bigr",
+		"biggl", "biggr", "Biggl", "Biggr", "middle":
+		return fence(p.delimiter()), false
+
+	case "begin":
+		return p.parseEnvironment(p.rawGroup()), false
+
+	case "\\", "right", "end":
+		return "", false
+	}
+
+	return "<merror><mtext>\\" + html.EscapeString(name) +
+		"</mtext></merror>", false
+}
+
+// delimiter returns the delimiter after \left, \right and the like. A
+// . is no delimiter at all.
+func (p *mathParser) delimiter() string {
+	t := p.next()
+	switch {
+	case t == nil:
+		return ""
+	case t.kind == mathCommand:
+		if s, ok := mathOperators[t.text]; ok {
+			return s
+		}
+		return ""
+	case t.text == ".":
+		return ""
+	}
+
+	return t.text
+}
+
+// fence returns a stretchy operator for the given delimiter.
+func fence(d string) string {
+	if d == "" {
+		return ""
+	}
+
+	return `<mo fence="true" stretchy="true">` + html.EscapeString(d) +
+		"</mo>"
+}
+
+// parseEnvironment parses the rows and columns of a matrix-like
+// environment up to its \end.
+func (p *mathParser) parseEnvironment(env string) string {
+	rows := [][]string{{}}
+
+	for {
+		cell := p.parseExpr(nil)
+		rows[len(rows)-1] = append(rows[len(rows)-1], mrow(cell))
+
+		t := p.next()
+		if t == nil {
+			break
+		}
+		if t.kind == mathClose {
+			// A stray closing brace; keep going.
+			continue
+		}
+		if isCommand(t, "end") {
+			p.rawGroup()
+			break
+		}
+		if isCommand(t, "\\") {
+			rows = append(rows, []string{})
+		}
+	}
+
+	// Drop the empty row after a trailing \\.
+	if last := rows[len(rows)-1]; len(rows) > 1 && len(last) == 1 &&
+		last[0] == "<mrow></mrow>" {
+		rows = rows[:len(rows)-1]
+	}
+
+	attrs := ""
+	if env == "cases" || strings.HasPrefix(env, "align") ||
+		env == "aligned" || env == "split" {
+		attrs = ` columnalign="left"`
+		if env != "cases" {
+			attrs = ` columnalign="right left"`
+		}
+	}
+
+	table := "<mtable" + attrs + ">"
+	for _, row := range rows {
+		table += "<mtr>"
+		for _, cell := range row {
+			table += "<mtd>" + cell + "</mtd>"
+		}
+		table += "</mtr>"
+	}
+	table += "</mtable>"
+
+	open, close := "", ""
+	switch strings.TrimSuffix(env, "*") {
+	case "pmatrix":
+		open, close = "(", ")"
+	case "bmatrix":
+		open, close = "[", "]"
+	case "Bmatrix", "cases":
+		open = "{"
+		if env != "cases" {
+			close = "}"
+		}
+	case "vmatrix":
+		open, close = "|", "|"
+	case "Vmatrix":
+		open, close = "‖", "‖"
+	}
+
+	if open == "" && close == "" {
+		return table
+	}
+
+	return "<mrow>" + fence(open) + table + fence(close) + "</mrow>"
+}
+
+// mrow returns the given nodes as a single node.
+func mrow(nodes []string) string {
+	if len(nodes) == 1 {
+		return nodes[0]
+	}
+
+	return "<mrow>" + strings.Join(nodes, "") + "</mrow>"
+}
+
+// mathOperatorNode returns the node for an operator character.
+func mathOperatorNode(op string) string {
+	switch op {
+	case "-":
+		op = "−"
+	case "*":
+		op = "∗"
+	}
+
+	return "<mo>" + html.EscapeString(op) + "</mo>"
+}
+
+// mathIdentifiers are the commands that are identifiers.
+var mathIdentifiers = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ",
+	"epsilon": "ϵ", "varepsilon": "ε", "zeta": "ζ", "eta": "η",
+	"theta": "θ", "vartheta": "ϑ", "iota": "ι", "kappa": "κ",
+	"lambda": "λ", "mu": "μ", "nu": "ν", "xi": "ξ", "pi": "π",
+	"varpi": "ϖ", "rho": "ρ", "varrho": "ϱ", "sigma": "σ",
+	"varsigma": "ς", "tau": "τ", "upsilon": "υ", "phi": "ϕ",
+	"varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω",
+	"infty": "∞", "partial": "∂", "nabla": "∇", "emptyset": "∅",
+	"varnothing": "∅", "ell": "ℓ", "hbar": "ℏ", "Re": "ℜ", "Im": "ℑ",
+	"aleph": "ℵ", "imath": "ı", "jmath": "ȷ",
+}
+
+// mathOperators are the commands that are operators.
+var mathOperators = map[string]string{
+	"times": "×", "cdot": "⋅", "div": "÷", "pm": "±", "mp": "∓",
+	"ast": "∗", "star": "⋆", "circ": "∘", "bullet": "∙",
+	"leq": "≤", "le": "≤", "geq": "≥", "ge": "≥", "neq": "≠", "ne": "≠",
+	"ll": "≪", "gg": "≫", "approx": "≈", "sim": "∼", "simeq": "≃",
+	"cong": "≅", "equiv": "≡", "propto": "∝", "perp": "⊥",
+	"parallel": "∥", "mid": "∣",
+	"in": "∈", "notin": "∉", "ni": "∋", "subset": "⊂", "supset": "⊃",
+	"subseteq": "⊆", "supseteq": "⊇", "cup": "∪", "cap": "∩",
+	"setminus": "∖", "wedge": "∧", "land": "∧", "vee": "∨", "lor": "∨",
+	"neg": "¬", "lnot": "¬", "forall": "∀", "exists": "∃", "nexists": "∄",
+	"to": "→", "rightarrow": "→", "leftarrow": "←", "gets": "←",
+	"leftrightarrow": "↔", "Rightarrow": "⇒", "Leftarrow": "⇐",
+	"Leftrightarrow": "⇔", "implies": "⟹", "iff": "⟺",
+	"mapsto": "↦", "uparrow": "↑", "downarrow": "↓",
+	"longrightarrow": "⟶", "longleftarrow": "⟵",
+	"ldots": "…", "cdots": "⋯", "vdots": "⋮", "ddots": "⋱",
+	"dots": "…", "prime": "′", "angle": "∠", "triangle": "△",
+	"oplus": "⊕", "otimes": "⊗", "odot": "⊙",
+	"langle": "⟨", "rangle": "⟩", "lceil": "⌈", "rceil": "⌉",
+	"lfloor": "⌊", "rfloor": "⌋", "vert": "|", "Vert": "‖", "|": "‖",
+	"lbrace": "{", "rbrace": "}", "{": "{", "}": "}",
+	"$": "$", "%": "%", "&": "&", "#": "#", "_": "_",
+}
+
+// mathLargeOperators are the commands that are large operators.
+var mathLargeOperators = map[string]string{
+	"sum": "∑", "prod": "∏", "coprod": "∐", "int": "∫", "iint": "∬",
+	"iiint": "∭", "oint": "∮", "bigcup": "⋃", "bigcap": "⋂",
+	"bigoplus": "⨁", "bigotimes": "⨂", "bigvee": "⋁",
+	"bigwedge": "⋀",
+}
+
+// mathFunctions are the commands that are the names of functions. The
+// ones that are true have their scripts above and below them in
+// display math.
+var mathFunctions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "sec": false,
+	"csc": false, "cot": false, "arcsin": false, "arccos": false,
+	"arctan": false, "sinh": false, "cosh": false, "tanh": false,
+	"coth": false, "log": false, "ln": false, "lg": false, "exp": false,
+	"det": true, "dim": false, "gcd": true, "deg": false, "arg": false,
+	"ker": false, "hom": false, "Pr": true,
+	"lim": true, "liminf": true, "limsup": true, "max": true,
+	"min": true, "sup": true, "inf": true,
+}
+
+// mathAccents are the commands that put something over their argument.
+var mathAccents = map[string]string{
+	"hat": "^", "widehat": "^", "bar": "¯", "overline": "¯",
+	"vec": "→", "overrightarrow": "→", "dot": "˙", "ddot": "¨",
+	"tilde": "~", "widetilde": "~", "check": "ˇ", "breve": "˘",
+	"acute": "´", "grave": "`",
+}
+
+// mathSpaces are the commands that are spaces.
+var mathSpaces = map[string]string{
+	",": "0.1667em", ":": "0.2222em", ">": "0.2222em", ";": "0.2778em",
+	" ": "0.25em", "quad": "1em", "qquad": "2em", "!": "0em",
+}
+
+// mathVariants are the commands that change the style of their
+// argument. The text ones are plain text.
+var mathVariants = map[string]string{
+	"mathbf": "bold", "boldsymbol": "bold-italic", "mathit": "italic",
+	"mathrm": "normal", "mathbb": "double-struck", "mathcal": "script",
+	"mathscr": "script", "mathfrak": "fraktur", "mathsf": "sans-serif",
+	"mathtt": "monospace", "operatorname": "normal",
+	"text": "text", "textrm": "text", "textit": "text", "textbf": "text",
+	"mbox": "text",
+}
@@ -0,0 +1,162 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// mathBody returns the MathML of the given math without the <math>
+// element and the annotation around it.
+func mathBody(mathml string) string {
+	start := strings.Index(mathml, "<semantics>") + len("<semantics>")
+	end := strings.Index(mathml, "<annotation")
+	if start < len("<semantics>") || end < start {
+		return mathml
+	}
+
+	return mathml[start:end]
+}
+
+func TestMathML(t *testing.T) {
+	tests := []struct {
+		tex     string
+		display bool
+		want    string
+	}{
+		{`x`, false, `<mi>x</mi>`},
+		{`x^2`, false, `<msup><mi>x</mi><mn>2</mn></msup>`},
+		{`x_i^2`, false, `<msubsup><mi>x</mi><mi>i</mi><mn>2</mn></msubsup>`},
+		{`f'`, false, `<msup><mi>f</mi><mo>′</mo></msup>`},
+		{`a < b`, false, `<mrow><mi>a</mi><mo>&lt;</mo><mi>b</mi></mrow>`},
+		{`a - b`, false, `<mrow><mi>a</mi><mo>−</mo><mi>b</mi></mrow>`},
+		{`\alpha + \Gamma`, false, `<mrow><mi>α</mi><mo>+</mo>` +
+			`<mi mathvariant="normal">Γ</mi></mrow>`},
+		{`\mathbf{v}`, false, `<mi mathvariant="bold">v</mi>`},
+		{`\text{if } x`, false, `<mrow><mtext>if </mtext><mi>x</mi></mrow>`},
+
+		// Fractions.
+		{`\frac{a}{b}`, false, `<mfrac><mi>a</mi><mi>b</mi></mfrac>`},
+		{`\frac12`, false, `<mfrac><mn>1</mn><mn>2</mn></mfrac>`},
+		{`\frac{a+1}{2}`, false, `<mfrac><mrow><mi>a</mi><mo>+</mo>` +
+			`<mn>1</mn></mrow><mn>2</mn></mfrac>`},
+		{`\binom{n}{k}`, false, `<mrow><mo>(</mo>` +
+			`<mfrac linethickness="0"><mi>n</mi><mi>k</mi></mfrac>` +
+			`<mo>)</mo></mrow>`},
+
+		// Roots.
+		{`\sqrt{x}`, false, `<msqrt><mi>x</mi></msqrt>`},
+		{`\sqrt[3]{x}`, false, `<mroot><mi>x</mi><mn>3</mn></mroot>`},
+		{`\sqrt[n+1]{x}`, false, `<mroot><mi>x</mi><mrow><mi>n</mi>` +
+			`<mo>+</mo><mn>1</mn></mrow></mroot>`},
+
+		// Large operators have their limits above and below them only
+		// in display math.
+		{`\sum_{i=1}^n i`, false, `<mrow><msubsup><mo>∑</mo><mrow>` +
+			`<mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></msubsup>` +
+			`<mi>i</mi></mrow>`},
+		{`\sum_{i=1}^n i`, true, `<mrow><munderover><mo>∑</mo><mrow>` +
+			`<mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi>` +
+			`</munderover><mi>i</mi></mrow>`},
+		{`\int_0^1 x`, true, `<mrow><msubsup><mo>∫</mo><mn>0</mn>` +
+			`<mn>1</mn></msubsup><mi>x</mi></mrow>`},
+
+		// Delimiters.
+		{`\left( \frac{a}{b} \right)`, false, `<mrow>` +
+			`<mo fence="true" stretchy="true">(</mo>` +
+			`<mfrac><mi>a</mi><mi>b</mi></mfrac>` +
+			`<mo fence="true" stretchy="true">)</mo></mrow>`},
+		{`\left. x \right|`, false, `<mrow><mi>x</mi>` +
+			`<mo fence="true" stretchy="true">|</mo></mrow>`},
+		{`\left\{ x \right.`, false, `<mrow>` +
+			`<mo fence="true" stretchy="true">{</mo><mi>x</mi></mrow>`},
+
+		// Environments.
+		{`\begin{matrix}a & b \\ c & d\end{matrix}`, false, `<mtable>` +
+			`<mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr>` +
+			`<mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr>` +
+			`</mtable>`},
+		{`\begin{pmatrix}a & b \\ c & d \\\end{pmatrix}`, false, `<mrow>` +
+			`<mo fence="true" stretchy="true">(</mo><mtable>` +
+			`<mtr><mtd><mi>a</mi></mtd><mtd><mi>b</mi></mtd></mtr>` +
+			`<mtr><mtd><mi>c</mi></mtd><mtd><mi>d</mi></mtd></mtr>` +
+			`</mtable><mo fence="true" stretchy="true">)</mo></mrow>`},
+		{`\begin{cases}1 & x > 0 \\ 0 & \text{otherwise}\end{cases}`,
+			false, `<mrow><mo fence="true" stretchy="true">{</mo>` +
+				`<mtable columnalign="left">` +
+				`<mtr><mtd><mn>1</mn></mtd><mtd><mrow><mi>x</mi>` +
+				`<mo>&gt;</mo><mn>0</mn></mrow></mtd></mtr>` +
+				`<mtr><mtd><mn>0</mn></mtd>` +
+				`<mtd><mtext>otherwise</mtext></mtd></mtr>` +
+				`</mtable></mrow>`},
+
+		// What isn't understood is shown as an error.
+		{`\foo`, false, `<merror><mtext>\foo</mtext></merror>`},
+	}
+
+	for _, test := range tests {
+		got := mathBody(MathML(test.tex, test.display))
+		if got != test.want {
+			t.Errorf("MathML(%q, %v) = %s, want %s", test.tex,
+				test.display, got, test.want)
+		}
+	}
+}
+
+func TestMathMLElement(t *testing.T) {
+	got := MathML(`a < b`, true)
+	want := `<math xmlns="http://www.w3.org/1998/Math/MathML" ` +
+		`display="block"><semantics>` +
+		`<mrow><mi>a</mi><mo>&lt;</mo><mi>b</mi></mrow>` +
+		`<annotation encoding="application/x-tex">a &lt; b</annotation>` +
+		`</semantics></math>`
+	if got != want {
+		t.Errorf("MathML = %s, want %s", got, want)
+	}
+}
+
+func TestRenderMath(t *testing.T) {
+	inline := func(tex string) string { return MathML(tex, false) }
+	display := func(tex string) string { return MathML(tex, true) }
+
+	tests := []struct {
+		markdown string
+		want     string
+	}{
+		{"Euler: $e^{i\\pi}$.", "Euler: " + inline(`e^{i\pi}`) + "."},
+		{"$$\\frac{a}{b}$$", display(`\frac{a}{b}`)},
+		{"$a$ and $b$", inline("a") + " and " + inline("b")},
+
+		// Dollar signs that aren't math.
+		{"It costs $5 and $10.", "It costs $5 and $10."},
+		{"A \\$ sign and $x$.", "A $ sign and " + inline("x") + "."},
+		{"\\$x\\$", "$x$"},
+		{"$ x$", "$ x$"},
+		{"$x $", "$x $"},
+		{"$x\n\ny$", "$x\n\ny$"},
+		{"$$x", "$$x"},
+
+		// Code and comments are left alone.
+		{"Code `$x$` and $y$.", "Code `$x$` and " + inline("y") + "."},
+		{"Code ``$x` $`` and $y$.", "Code ``$x` $`` and " + inline("y") +
+			"."},
+		{"$x `y$` z", "$x `y$` z"},
+		{"```\n$x$\n```\n$y$", "```\n$x$\n```\n" + inline("y")},
+		{"~~~\n$x$\n~~~\n", "~~~\n$x$\n~~~\n"},
+		{"Text\n\n    $x$\n\n$y$", "Text\n\n    $x$\n\n" + inline("y")},
+		{"Text\n    $x$", "Text\n    " + inline("x")},
+		{"<!-- $x$ --> $y$", "<!-- $x$ --> " + inline("y")},
+	}
+
+	for _, test := range tests {
+		ph := &placeholders{}
+		got := ph.restore(RenderMath(test.markdown, ph))
+		if got != test.want {
+			t.Errorf("RenderMath(%q) = %q, want %q", test.markdown, got,
+				test.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"golang.org/x/crypto/pbkdf2"
+	"text/template"
+)
+
+// pbkdf2Iterations is the number of iterations used to derive the key
+// from the password of a protected entry.
+const pbkdf2Iterations = 100000
+
+// protectedForm is the HTML that replaces the content of a protected
+// entry. The browser derives the key from the password the reader
+// enters and decrypts the content using the Web Crypto API.
+var protectedForm = template.Must(template.New("protected").Parse(
+	`<form class="goblog-protected" data-salt="{{.Salt}}" ` +
+		`data-nonce="{{.Nonce}}" data-content="{{.Content}}" ` +
+		`data-iterations="{{.Iterations}}" ` +
+		`onsubmit="return goblogDecrypt(this)">
+<label>{{html .Label}} <input type="password" name="password" autocomplete="current-password"></label>
+<button type="submit">{{html .Button}}</button>
+<p class="goblog-error" hidden>{{html .Error}}</p>
+</form>
+<script>
+function goblogDecrypt(form) {
+  var bytes = function (s) {
+    return Uint8Array.from(atob(s), function (c) { return c.charCodeAt(0); });
+  };
+  var password = new TextEncoder().encode(form.password.value);
+  crypto.subtle.importKey("raw", password, "PBKDF2", false, ["deriveKey"])
+    .then(function (key) {
+      return crypto.subtle.deriveKey({
+        name: "PBKDF2",
+        salt: bytes(form.dataset.salt),
+        iterations: parseInt(form.dataset.iterations, 10),
+        hash: "SHA-256"
+      }, key, {name: "AES-GCM", length: 256}, false, ["decrypt"]);
+    })
+    .then(function (key) {
+      return crypto.subtle.decrypt({name: "AES-GCM",
+        iv: bytes(form.dataset.nonce)}, key, bytes(form.dataset.content));
+    })
+    .then(function (content) {
+      var div = document.createElement("div");
+      div.innerHTML = new TextDecoder().decode(content);
+      form.parentNode.replaceChild(div, form);
+    })
+    .catch(function () {
+      form.querySelector(".goblog-error").hidden = false;
+    });
+  return false;
+}
+</script>`))
+
+// Protect encrypts the given HTML content with AES-GCM using a key
+// derived from the given password with PBKDF2. It returns a form that
+// decrypts it in the reader's browser. The labels of the form are
+// translated with the given Locale.
+func Protect(content, password string, l *Locale) (string, error) {
+	salt := make([]byte, 16)
+	_, err := rand.Read(salt)
+	if err != nil {
+		return "", err
+	}
+
+	key := pbkdf2.Key([]byte(password), salt, pbkdf2Iterations, 32,
+		sha256.New)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nil, nonce, []byte(content), nil)
+
+	// Make the form.
+	data := struct {
+		Salt       string
+		Nonce      string
+		Content    string
+		Iterations int
+		Label      string
+		Button     string
+		Error      string
+	}{
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Nonce:      base64.StdEncoding.EncodeToString(nonce),
+		Content:    base64.StdEncoding.EncodeToString(sealed),
+		Iterations: pbkdf2Iterations,
+		Label:      l.T("Password"),
+		Button:     l.T("Unlock"),
+		Error:      l.T("That password is not correct."),
+	}
+
+	buf := new(bytes.Buffer)
+	err = protectedForm.Execute(buf, data)
+	if err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
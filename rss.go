@@ -15,16 +15,19 @@ import (
 )
 
 var sfeed = `<?xml version="1.0" encoding="UTF-8" ?>
-<rss version="2.0">
+<rss version="2.0" xmlns:media="http://search.yahoo.com/mrss/">
   <channel>
     <lastBuildDate>{{.CreateDate}}</lastBuildDate> 
 {{.ChannelContent}} 
 {{range .Blogs}}    <item>
       <title>{{.Title}}</title>
-      <link>%s{{.Url}}</link>
-      <description>{{.Description}}</description>
+      <link>%[1]s{{.SiteUrl}}</link>
+      <description>{{if .Description}}{{.Description}}{{else}}{{html .Summary}}{{end}}</description>
       <pubDate>{{.PubDate}}</pubDate>
-{{range .Tags}}      <category>{{.}}</category>
+{{with enclosure .}}      <enclosure url="%[1]s{{.Url}}" length="{{.Length}}" type="{{.Type}}" />
+      <media:content url="%[1]s{{.Url}}" medium="image" type="{{.Type}}" />
+{{end}}{{with .Thumbnail}}      <media:thumbnail url="%[1]s{{.Url}}" width="{{.Width}}" height="{{.Height}}" />
+{{end}}{{range .Tags}}      <category>{{.}}</category>
 {{end}}    </item>
 {{end}}
   </channel>
@@ -32,8 +35,15 @@ var sfeed = `<?xml version="1.0" encoding="UTF-8" ?>
 
 // MakeRss creates a completed feed.rss xml document and puts it into
 // the given directory. It uses the template from channel.rss to
-// populated the channel values except for the <item>s.
+// populated the channel values except for the <item>s. The featured
+// images of the entries are enclosed in their <item>s.
 func MakeRss(entries []*Entry, url, tdir, dir string) error {
+	return MakeFeed(entries, url, tdir, path.Join(dir, "feed.rss"))
+}
+
+// MakeFeed creates a completed RSS xml document like MakeRss does but
+// writes it to the given file.
+func MakeFeed(entries []*Entry, url, tdir, file string) error {
 
 	// Get the channel data.
 	channelContent, err := ioutil.ReadFile(path.Join(tdir, "channel.rss"))
@@ -54,7 +64,9 @@ func MakeRss(entries []*Entry, url, tdir, dir string) error {
 
 	feed := fmt.Sprintf(sfeed, url)
 
-	var tmplt = template.Must(template.New("rss").Parse(feed))
+	var tmplt = template.Must(template.New("rss").Funcs(template.FuncMap{
+		"enclosure": enclosure,
+	}).Parse(feed))
 
 	// Make the data that will be passed to the templater.
 	data := struct {
@@ -63,7 +75,7 @@ func MakeRss(entries []*Entry, url, tdir, dir string) error {
 		ChannelContent string
 	}{
 		Blogs:          entries,
-		CreateDate:     time.Now().Format(time.RFC822),
+		CreateDate:     time.Now().In(Location).Format(time.RFC822),
 		ChannelContent: string(channelContent),
 	}
 
@@ -75,7 +87,7 @@ func MakeRss(entries []*Entry, url, tdir, dir string) error {
 	}
 
 	// Write out the file.
-	err = ioutil.WriteFile(path.Join(dir, "feed.rss"), sw.Bytes(), 0644)
+	err = ioutil.WriteFile(file, sw.Bytes(), 0644)
 
 	return err
 }
@@ -0,0 +1,227 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"os"
+	"sort"
+)
+
+// Sections are the content sections of the site. The first one is
+// always the blog in the BlogDir. The rest come from the SectionsFile.
+var Sections = []*Section{}
+
+// Section is a directory of entries that are displayed in their own
+// way. Sections are loaded from the SectionsFile, which is a TOML file
+// with a table for each section keyed by its name:
+//
+//      [notes]
+//      title = "Notes"
+//      dir = "notes"
+//      entry_template = "note"
+//      list_template = "notes"
+//      archive_template = "archive"
+//      sort = "title"
+//      in_index = false
+//      in_feed = false
+//
+// The entries of a section are put in a directory with its name (e.g.
+// notes/first.html).
+type Section struct {
+	// Name is the key of the section in the SectionsFile. It's also the
+	// directory its pages are put in. It's "" for the blog.
+	Name string
+
+	// Title is the title of the section's pages.
+	Title string `toml:"title"`
+
+	// Dir is the directory where the section's entries can be found.
+	// It defaults to the Name.
+	Dir string `toml:"dir"`
+
+	// EntryTemplate is the name of the template (without .html) used to
+	// display a single entry. It defaults to entry.
+	EntryTemplate string `toml:"entry_template"`
+
+	// ListTemplate is the name of the template used for the page that
+	// lists the section's entries. If it's empty, there isn't one.
+	ListTemplate string `toml:"list_template"`
+
+	// ArchiveTemplate is the name of the template used for the
+	// section's archive. If it's empty, there isn't one.
+	ArchiveTemplate string `toml:"archive_template"`
+
+	// Sort is the order of the entries on the section's list page. See
+	// SortEntries for the orders. Pinned entries are always first.
+	Sort string `toml:"sort"`
+
+	// InIndex determines whether or not the section's entries are on
+	// the index and archive pages of the site.
+	InIndex bool `toml:"in_index"`
+
+	// InFeed determines whether or not the section's entries are in
+	// the RSS feed of the site.
+	InFeed bool `toml:"in_feed"`
+}
+
+// Url returns the url of the page that lists the section's entries
+// relative to the root of the site in the page's language.
+func (s *Section) Url() string {
+	return s.Name + "/index.html"
+}
+
+// ArchiveUrl returns the url of the section's archive relative to the
+// root of the site in the page's language.
+func (s *Section) ArchiveUrl() string {
+	return s.Name + "/archives.html"
+}
+
+// templates returns the names of the templates the section uses.
+func (s *Section) templates() []string {
+	names := []string{}
+	for _, name := range []string{s.EntryTemplate, s.ListTemplate,
+		s.ArchiveTemplate} {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// BlogSection returns the Section for the entries in the BlogDir.
+func BlogSection() *Section {
+	return &Section{
+		Dir:           BlogDir,
+		EntryTemplate: "entry",
+		InIndex:       true,
+		InFeed:        true,
+	}
+}
+
+// LoadSections reads the sections from the given TOML file. They are
+// sorted by their name. Their names can't be the ones the site already
+// uses (see reservedNames). It's fine if the file doesn't exist.
+func LoadSections(file string) ([]*Section, error) {
+	sections := map[string]*Section{}
+
+	_, err := toml.DecodeFile(file, &sections)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*Section{}, nil
+		}
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := []*Section{}
+	for _, name := range names {
+		s := sections[name]
+		s.Name = name
+
+		slug, err := MakeBlogName(name)
+		if err != nil {
+			return nil, err
+		}
+		if slug != name {
+			return nil, fmt.Errorf("invalid section name: %s", name)
+		}
+		for _, r := range reservedNames() {
+			if name == r {
+				return nil, fmt.Errorf("reserved section name: %s", name)
+			}
+		}
+
+		if s.Title == "" {
+			s.Title = TaxonomyKey(name)
+		}
+		if s.Dir == "" {
+			s.Dir = name
+		}
+		if _, err := SortEntries(nil, s.Sort); err != nil {
+			return nil, fmt.Errorf("section %s: %v", name, err)
+		}
+		if s.EntryTemplate == "" {
+			s.EntryTemplate = "entry"
+		}
+
+		result = append(result, s)
+	}
+
+	return result, nil
+}
+
+// GetSection returns the Section with the given name. If there isn't
+// one, the blog's Section is returned.
+func GetSection(name string) *Section {
+	for _, s := range Sections {
+		if s.Name == name {
+			return s
+		}
+	}
+
+	return BlogSection()
+}
+
+// pageSection returns the name of the section the page that will be
+// written to the given file is in based on the directory it's in.
+func pageSection(file string) string {
+	parts := pageDirs(file)
+	if len(parts) > 0 && LanguageDir(pageLanguage(file)) != "" {
+		parts = parts[1:]
+	}
+
+	if len(parts) > 0 {
+		for _, s := range Sections {
+			if s.Name != "" && s.Name == parts[0] {
+				return s.Name
+			}
+		}
+	}
+
+	return ""
+}
+
+// SectionEntries returns the entries that are in the given section.
+func SectionEntries(entries []*Entry, s *Section) []*Entry {
+	return filterEntries(entries, func(e *Entry) bool {
+		return e.Section == s.Name
+	})
+}
+
+// IndexEntries returns the entries that belong on the index and
+// archive pages of the site.
+func IndexEntries(entries []*Entry) []*Entry {
+	return filterEntries(entries, func(e *Entry) bool {
+		return GetSection(e.Section).InIndex
+	})
+}
+
+// FeedEntries returns the entries that belong in the RSS feed of the
+// site.
+func FeedEntries(entries []*Entry) []*Entry {
+	return filterEntries(entries, func(e *Entry) bool {
+		return GetSection(e.Section).InFeed
+	})
+}
+
+// filterEntries returns the entries for which keep returns true.
+func filterEntries(entries []*Entry, keep func(*Entry) bool) []*Entry {
+	result := []*Entry{}
+	for _, e := range entries {
+		if keep(e) {
+			result = append(result, e)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,367 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"html"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Site is what the site says about itself in channel.rss. It's used
+// for the social metadata of the pages.
+var Site = &SiteInfo{}
+
+// SiteInfo is the name, link and description of the site.
+type SiteInfo struct {
+	Title       string
+	Link        string
+	Description string
+}
+
+// LoadSiteInfo reads the <title>, <link> and <description> from the
+// given channel.rss file. It's fine if the file doesn't exist.
+func LoadSiteInfo(file string) (*SiteInfo, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &SiteInfo{}, nil
+		}
+		return nil, err
+	}
+
+	// Only the first of each belongs to the channel.
+	value := func(tag string) string {
+		re := regexp.MustCompile("(?s)<" + tag + ">(.*?)</" + tag + ">")
+		found := re.FindSubmatch(data)
+		if len(found) < 2 {
+			return ""
+		}
+		return strings.TrimSpace(html.UnescapeString(string(found[1])))
+	}
+
+	return &SiteInfo{
+		Title:       value("title"),
+		Link:        value("link"),
+		Description: value("description"),
+	}, nil
+}
+
+// SEO is the metadata a page gives search engines and social networks:
+// a canonical url, Open Graph and Twitter card meta tags and a JSON-LD
+// object. The HTML method returns all of them ready to be put in the
+// <head> of a page.
+type SEO struct {
+	// Canonical is the url of the page. It's absolute if the site's
+	// URL is known from the url flag or channel.rss.
+	Canonical string
+
+	// Title is the title of the page.
+	Title string
+
+	// Description is the description of the page.
+	Description string
+
+	// Type is the Open Graph type of the page: "article" for entries
+	// and "website" for everything else.
+	Type string
+
+	// SiteName is the name of the site.
+	SiteName string
+
+	// Language is the language of the page.
+	Language string
+
+	// Image is the url of the image to show when the page is shared or
+	// "" if there isn't one. ImageAlt describes it.
+	Image    string
+	ImageAlt string
+
+	// Authors are the names of the people who wrote the page.
+	Authors []string
+
+	// Published and Modified are when the page was created and last
+	// updated. They are zero if unknown.
+	Published time.Time
+	Modified  time.Time
+
+	// Tags are the tags of the page.
+	Tags []string
+}
+
+// MetaTag is a <meta> tag. Its Name is the property of Open Graph tags
+// and the name of the others.
+type MetaTag struct {
+	Name    string
+	Content string
+}
+
+// siteLink returns the url of the site with a trailing slash or "" if
+// it isn't known.
+func siteLink() string {
+	link := URL
+	if link == "" {
+		link = Site.Link
+	}
+	if link == "" {
+		return ""
+	}
+
+	return strings.TrimSuffix(link, "/") + "/"
+}
+
+// absoluteUrl makes the given url relative to the root of the site
+// absolute if the site's url is known. Otherwise, it's relative to the
+// given root.
+func absoluteUrl(root, url string) string {
+	if strings.Contains(url, "://") {
+		return url
+	}
+
+	if link := siteLink(); link != "" {
+		return link + strings.TrimPrefix(url, "/")
+	}
+
+	return root + strings.TrimPrefix(url, "/")
+}
+
+// pageSEO returns the metadata of the page with the given SiteData
+// that will be written to the given file.
+func pageSEO(file string, sd *SiteData) *SEO {
+	s := &SEO{
+		Title:       sd.Title,
+		Description: sd.Description,
+		Type:        "website",
+		SiteName:    Site.Title,
+		Language:    sd.Language,
+	}
+
+	if rel, err := filepath.Rel(OutputDir, file); err == nil {
+		rel = filepath.ToSlash(rel)
+		if siteLink() != "" {
+			rel = strings.TrimSuffix(rel, "index.html")
+		}
+		s.Canonical = absoluteUrl(NewHelper(file).Root, rel)
+	}
+
+	if s.Title == "" {
+		s.Title = Site.Title
+	}
+	if s.Description == "" {
+		s.Description = Site.Description
+	}
+	if sd.Author != "" {
+		s.Authors = []string{sd.Author}
+	}
+
+	return s
+}
+
+// entrySEO returns the metadata of the page of the given entry.
+func entrySEO(file string, blog *Entry, sd *SiteData) *SEO {
+	s := pageSEO(file, sd)
+	s.Type = "article"
+	s.Published = blog.Created
+	s.Modified = blog.Updated
+	s.Tags = blog.Tags
+
+	// Entries show their featured image when they are shared or, if
+	// they don't have one, their social card.
+	if blog.Image != "" {
+		s.Image = absoluteUrl(NewHelper(file).Root, blog.Image)
+		s.ImageAlt = blog.ImageAlt
+	} else {
+		s.Image = absoluteUrl(NewHelper(file).Root, path.Join(
+			LanguageDir(blog.Language), blog.CardUrl()))
+		s.ImageAlt = blog.Title
+	}
+
+	// Don't give away what's in protected entries.
+	if blog.Description == "" && !blog.Protected() {
+		s.Description = descriptionOf(blog.Summary)
+	}
+
+	if len(blog.Authors) > 0 {
+		s.Authors = []string{}
+		for _, a := range blog.Authors {
+			s.Authors = append(s.Authors, a.Name)
+		}
+	}
+
+	return s
+}
+
+// descriptionOf returns the text of the given HTML shortened to about
+// the length search engines show.
+func descriptionOf(content string) string {
+	words := strings.Fields(PlainText(content))
+	desc := ""
+	for _, w := range words {
+		if len(desc)+len(w) > 160 {
+			return desc + "…"
+		}
+		if desc != "" {
+			desc += " "
+		}
+		desc += w
+	}
+
+	return desc
+}
+
+// OpenGraph returns the Open Graph <meta> tags of the page. Their
+// Name is the property.
+func (s *SEO) OpenGraph() []*MetaTag {
+	tags := []*MetaTag{}
+	add := func(name, content string) {
+		if content != "" {
+			tags = append(tags, &MetaTag{Name: name, Content: content})
+		}
+	}
+
+	add("og:title", s.Title)
+	add("og:description", s.Description)
+	add("og:type", s.Type)
+	add("og:url", s.Canonical)
+	add("og:site_name", s.SiteName)
+	add("og:locale", strings.Replace(s.Language, "-", "_", -1))
+	add("og:image", s.Image)
+	add("og:image:alt", s.ImageAlt)
+
+	if s.Type == "article" {
+		if !s.Published.IsZero() {
+			add("article:published_time", s.Published.Format(time.RFC3339))
+		}
+		if !s.Modified.IsZero() {
+			add("article:modified_time", s.Modified.Format(time.RFC3339))
+		}
+		for _, a := range s.Authors {
+			add("article:author", a)
+		}
+		for _, t := range s.Tags {
+			add("article:tag", t)
+		}
+	}
+
+	return tags
+}
+
+// Twitter returns the Twitter card <meta> tags of the page.
+func (s *SEO) Twitter() []*MetaTag {
+	card := "summary"
+	if s.Image != "" {
+		card = "summary_large_image"
+	}
+
+	tags := []*MetaTag{{Name: "twitter:card", Content: card}}
+	add := func(name, content string) {
+		if content != "" {
+			tags = append(tags, &MetaTag{Name: name, Content: content})
+		}
+	}
+
+	add("twitter:title", s.Title)
+	add("twitter:description", s.Description)
+	add("twitter:image", s.Image)
+	add("twitter:image:alt", s.ImageAlt)
+
+	return tags
+}
+
+// JSONLD returns the JSON-LD object of the page. It's a BlogPosting
+// for entries and a WebSite for everything else.
+func (s *SEO) JSONLD() string {
+	data := map[string]interface{}{
+		"@context": "https://schema.org",
+		"@type":    "WebSite",
+		"url":      s.Canonical,
+	}
+
+	if s.Type == "article" {
+		data["@type"] = "BlogPosting"
+		data["headline"] = s.Title
+		data["mainEntityOfPage"] = s.Canonical
+		if s.SiteName != "" {
+			data["publisher"] = map[string]string{
+				"@type": "Organization",
+				"name":  s.SiteName,
+			}
+		}
+		if !s.Published.IsZero() {
+			data["datePublished"] = s.Published.Format(time.RFC3339)
+		}
+		if !s.Modified.IsZero() {
+			data["dateModified"] = s.Modified.Format(time.RFC3339)
+		}
+		if len(s.Authors) > 0 {
+			authors := []map[string]string{}
+			for _, a := range s.Authors {
+				authors = append(authors, map[string]string{
+					"@type": "Person",
+					"name":  a,
+				})
+			}
+			data["author"] = authors
+		}
+		if len(s.Tags) > 0 {
+			data["keywords"] = strings.Join(s.Tags, ", ")
+		}
+	} else {
+		data["name"] = s.Title
+	}
+
+	if s.Description != "" {
+		data["description"] = s.Description
+	}
+	if s.Image != "" {
+		data["image"] = s.Image
+	}
+	if s.Language != "" {
+		data["inLanguage"] = s.Language
+	}
+
+	// Marshaling escapes <, > and &, so it's safe in a <script>.
+	js, err := json.Marshal(data)
+	if err != nil {
+		return "{}"
+	}
+
+	return string(js)
+}
+
+// HTML returns the canonical <link>, the <meta> tags and the JSON-LD
+// <script> of the page. Put it in the <head> of site.html:
+//
+//      {{.SEO.HTML}}
+func (s *SEO) HTML() string {
+	buf := new(strings.Builder)
+
+	if s.Canonical != "" {
+		buf.WriteString(`<link rel="canonical" href="` +
+			html.EscapeString(s.Canonical) + "\">\n")
+	}
+	if s.Description != "" {
+		buf.WriteString(`<meta name="description" content="` +
+			html.EscapeString(s.Description) + "\">\n")
+	}
+	for _, t := range s.OpenGraph() {
+		buf.WriteString(`<meta property="` + html.EscapeString(t.Name) +
+			`" content="` + html.EscapeString(t.Content) + "\">\n")
+	}
+	for _, t := range s.Twitter() {
+		buf.WriteString(`<meta name="` + html.EscapeString(t.Name) +
+			`" content="` + html.EscapeString(t.Content) + "\">\n")
+	}
+	buf.WriteString(`<script type="application/ld+json">` + s.JSONLD() +
+		"</script>\n")
+
+	return buf.String()
+}
@@ -0,0 +1,165 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Series is a representation of a series of blog entries that are
+// meant to be read in order. It is used as a storage mechanism for the
+// series pages.
+type Series struct {
+	// The name of the Series.
+	Name string
+
+	// The normalized name of the Series (see NormalizeTag). Entries
+	// whose series names normalize to it are part of the Series.
+	Key string
+
+	// The name of the Series as it's used for urls.
+	Slug string
+
+	// The parts of the Series in order.
+	Parts []*Entry
+}
+
+// Url returns the url of the series page relative to the root of the
+// site in the page's language.
+func (s *Series) Url() string {
+	return "series/" + s.Slug + ".html"
+}
+
+// Add links the given Entry to this Series.
+func (s *Series) Add(e *Entry) {
+	s.Parts = append(s.Parts, e)
+}
+
+// languages returns the languages the series has been written in.
+func (s *Series) languages() []string {
+	return versionLanguages(s.Parts, func(e *Entry) bool {
+		return NormalizeTag(e.SeriesName) == s.Key
+	})
+}
+
+// SeriesPart is the place of an entry within its Series. It is what
+// entry.html sees as .Series.
+type SeriesPart struct {
+	*Series
+
+	// Index is the number of the entry within the Series starting at
+	// 1.
+	Index int
+
+	// Prev is the part before the entry or nil if it's the first one.
+	Prev *Entry
+
+	// Next is the part after the entry or nil if it's the last one.
+	Next *Entry
+}
+
+// SeriesSet is a map of Series structures keyed by their Key with
+// some methods for easily adding blog entries. It also has the ability
+// to export the series as a sorted list for output.
+type SeriesSet map[string]*Series
+
+// GetSeries builds a SeriesSet from the given list of blogs. The parts
+// of each series are sorted and the Series of each entry is set.
+func GetSeries(entries []*Entry) (SeriesSet, error) {
+	ss := make(SeriesSet)
+
+	for _, blog := range entries {
+		err := ss.Add(blog)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, s := range ss {
+		sort.Stable(seriesParts(s.Parts))
+
+		for i, e := range s.Parts {
+			part := &SeriesPart{Series: s, Index: i + 1}
+			if i > 0 {
+				part.Prev = s.Parts[i-1]
+			}
+			if i < len(s.Parts)-1 {
+				part.Next = s.Parts[i+1]
+			}
+
+			e.Series = part
+		}
+	}
+
+	return ss, nil
+}
+
+// Add links the given Entry to its series if it has one. Series names
+// are compared like tags, so "Learning Go" and "learning go" are the
+// same series.
+func (ss SeriesSet) Add(e *Entry) error {
+	if e.SeriesName == "" {
+		return nil
+	}
+
+	key := NormalizeTag(e.SeriesName)
+	s, ok := ss[key]
+	if !ok {
+		// It wasn't found, so create one.
+		slug, err := MakeBlogName(key)
+		if err != nil {
+			return err
+		}
+
+		// Different series can't share a page.
+		for _, other := range ss {
+			if other.Slug == slug {
+				return fmt.Errorf("series %q and %q have the same url: %s",
+					other.Name, e.SeriesName, slug)
+			}
+		}
+
+		s = &Series{
+			Name:  e.SeriesName,
+			Key:   key,
+			Slug:  slug,
+			Parts: []*Entry{},
+		}
+		ss[key] = s
+	}
+
+	s.Add(e)
+
+	return nil
+}
+
+// seriesParts is a slice of entries that implements the sorting
+// interface for go's sort package. Entries are sorted by their
+// SeriesOrder and then by the date they were created.
+type seriesParts []*Entry
+
+// Len returns the length of the seriesParts.
+func (s seriesParts) Len() int {
+	return len(s)
+}
+
+// Less returns true if the value at i is less than the value at j.
+func (s seriesParts) Less(i, j int) bool {
+	if s[i].SeriesOrder != s[j].SeriesOrder {
+		return s[i].SeriesOrder < s[j].SeriesOrder
+	}
+
+	if !s[i].Created.Equal(s[j].Created) {
+		return s[i].Created.Before(s[j].Created)
+	}
+
+	return s[i].Name < s[j].Name
+}
+
+// Swap switches the elemens at i and j.
+func (s seriesParts) Swap(i, j int) {
+	s[i], s[j] = s[j], s[i]
+}
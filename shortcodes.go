@@ -0,0 +1,366 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"text/template"
+)
+
+// Shortcodes are the templates for the shortcodes keyed by their
+// name. They are loaded from the shortcodes directory of the
+// TemplateDir (e.g. templates/shortcodes/figure.html).
+var Shortcodes = map[string]*template.Template{}
+
+// shortcodeRegex matches a shortcode tag like {{< name key="value" >}}
+// or {{< /name >}}. A tag that ends with />}} has no content.
+var shortcodeRegex = regexp.MustCompile(
+	`{{<\s*(/?)\s*([-\w]+)((?:\s+[-\w]+\s*=\s*"[^"]*")*)\s*(/?)>}}`)
+
+// shortcodeParamRegex matches the parameters of a shortcode tag.
+var shortcodeParamRegex = regexp.MustCompile(`([-\w]+)\s*=\s*"([^"]*)"`)
+
+// shortcodeEscapeRegex matches a shortcode tag that should be left as
+// is, like {{</* name */>}}.
+var shortcodeEscapeRegex = regexp.MustCompile(`{{</\*(.*?)\*/>}}`)
+
+// LoadShortcodes reads a template for each HTML file in the given
+// directory. The name of the file is the name of the shortcode. It's
+// fine if the directory doesn't exist.
+func LoadShortcodes(dir string) (map[string]*template.Template, error) {
+	shortcodes := map[string]*template.Template{}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return shortcodes, nil
+		}
+		return nil, err
+	}
+
+	for _, file := range files {
+		if file.IsDir() || path.Ext(file.Name()) != ".html" {
+			continue
+		}
+
+		contents, err := ioutil.ReadFile(path.Join(dir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSuffix(file.Name(), ".html")
+		tmplt, err := template.New(name).Funcs(templateFuncs).
+			Option("missingkey=zero").Parse(string(contents))
+		if err != nil {
+			return nil, err
+		}
+
+		shortcodes[name] = tmplt
+	}
+
+	return shortcodes, nil
+}
+
+// placeholders keeps HTML out of the way of the markdown renderer. Each
+// piece of HTML is replaced by a token that is swapped back once the
+// markdown has been rendered.
+type placeholders []string
+
+// add returns the token for the given HTML.
+func (p *placeholders) add(value string) string {
+	*p = append(*p, value)
+	return fmt.Sprintf("GOBLOGPLACEHOLDER%dX", len(*p)-1)
+}
+
+// restore swaps the tokens in the given HTML with what they stand for.
+// A token that became a paragraph of its own is replaced along with the
+// paragraph.
+func (p placeholders) restore(content string) string {
+	for i := len(p) - 1; i >= 0; i-- {
+		token := fmt.Sprintf("GOBLOGPLACEHOLDER%dX", i)
+		content = strings.Replace(content, "<p>"+token+"</p>", p[i], -1)
+		content = strings.Replace(content, token, p[i], -1)
+	}
+
+	return content
+}
+
+// codeRanges returns where the code blocks and code spans are in the
+// given markdown as pairs of offsets. Code blocks are fenced with ```
+// or ~~~ or indented after a blank line. Backticks in HTML comments
+// don't start code spans.
+func codeRanges(md string) [][2]int {
+	ranges := [][2]int{}
+	text := 0   // where the text since the last code block starts
+	block := -1 // where the current code block starts
+	fence := ""
+	prevBlank := true
+
+	start := func(offset int) {
+		ranges = append(ranges, codeSpans(md, text, offset)...)
+		block = offset
+	}
+	end := func(offset int) {
+		ranges = append(ranges, [2]int{block, offset})
+		block = -1
+		text = offset
+	}
+
+	offset := 0
+	for _, line := range strings.SplitAfter(md, "\n") {
+		lineStart := offset
+		offset += len(line)
+		trimmed := strings.TrimLeft(line, " ")
+		blank := strings.TrimSpace(line) == ""
+
+		switch {
+		case fence != "":
+			if strings.HasPrefix(trimmed, fence) {
+				fence = ""
+				end(offset)
+			}
+			continue
+		case len(line)-len(trimmed) < 4 &&
+			(strings.HasPrefix(trimmed, "```") ||
+				strings.HasPrefix(trimmed, "~~~")):
+			if block >= 0 {
+				end(lineStart)
+			}
+			start(lineStart)
+			fence = trimmed[:3]
+			continue
+		case !blank && (prevBlank || block >= 0) &&
+			(strings.HasPrefix(line, "    ") ||
+				strings.HasPrefix(line, "\t")):
+			if block < 0 {
+				start(lineStart)
+			}
+			continue
+		}
+
+		if block >= 0 && !blank {
+			end(lineStart)
+		}
+		prevBlank = blank
+	}
+
+	if block >= 0 {
+		end(len(md))
+	}
+
+	return append(ranges, codeSpans(md, text, len(md))...)
+}
+
+// codeSpans is a helper function for codeRanges that finds the code
+// spans in the given markdown between the given offsets. Code spans
+// end with the same number of backticks they start with.
+func codeSpans(md string, from, to int) [][2]int {
+	ranges := [][2]int{}
+
+	for i := from; i < to; {
+		switch {
+		case strings.HasPrefix(md[i:to], "<!--"):
+			end := strings.Index(md[i:to], "-->")
+			if end < 0 {
+				return ranges
+			}
+			i += end + 3
+
+		case md[i] == '`':
+			n := 1
+			for i+n < to && md[i+n] == '`' {
+				n++
+			}
+			end := strings.Index(md[i+n:to], md[i:i+n])
+			if end < 0 {
+				i += n
+				continue
+			}
+			ranges = append(ranges, [2]int{i, i + n + end + n})
+			i += n + end + n
+
+		default:
+			i++
+		}
+	}
+
+	return ranges
+}
+
+// inRanges returns true if the given offset is in one of the given
+// ranges.
+func inRanges(ranges [][2]int, offset int) bool {
+	for _, r := range ranges {
+		if offset >= r[0] && offset < r[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// RenderShortcodes replaces the shortcodes in the given markdown of the
+// given entry with the results of their templates. The results are
+// kept in the given placeholders so the markdown renderer doesn't touch
+// them. Shortcodes can be nested; the inner ones are rendered first.
+// Shortcodes in code are left alone. Each template gets the following
+// values:
+//
+//      .Params - The parameters of the shortcode keyed by their name.
+//      .Inner  - The content between the opening and closing tags. It
+//                can be rendered with {{markdown .Inner}}, which
+//                renders it like the rest of the entry.
+//      .Entry  - The entry the shortcode is in.
+//      .Data   - The contents of the data files. See LoadData.
+func RenderShortcodes(markdown string, e *Entry,
+	ph *placeholders) (string, error) {
+
+	markdown = shortcodeEscapeRegex.ReplaceAllStringFunc(markdown,
+		func(tag string) string {
+			m := shortcodeEscapeRegex.FindStringSubmatch(tag)
+			return ph.add(html.EscapeString("{{<" + m[1] + ">}}"))
+		})
+
+	return renderShortcodes(markdown, e, ph)
+}
+
+// renderShortcodes is a helper function for RenderShortcodes that
+// renders the shortcodes in the given content. If ph is nil, the
+// results are put in place rather than kept in placeholders.
+func renderShortcodes(content string, e *Entry,
+	ph *placeholders) (string, error) {
+
+	result := ""
+	code := codeRanges(content)
+	last := 0
+
+	for _, m := range shortcodeRegex.FindAllStringSubmatchIndex(content, -1) {
+		// Skip the ones in code and in shortcodes we've already done.
+		if m[0] < last || inRanges(code, m[0]) {
+			continue
+		}
+
+		result += content[last:m[0]]
+		name := content[m[4]:m[5]]
+		if m[3] > m[2] {
+			return "", fmt.Errorf("closing shortcode without an opening "+
+				"one: %s", name)
+		}
+
+		params := map[string]string{}
+		for _, p := range shortcodeParamRegex.FindAllStringSubmatch(
+			content[m[6]:m[7]], -1) {
+			params[p[1]] = p[2]
+		}
+
+		// Find the content up to the closing tag, if there is one.
+		inner := ""
+		last = m[1]
+		if m[9] == m[8] {
+			start, end := closingShortcode(content, m[1], name, code)
+			if start >= 0 {
+				inner = content[m[1]:start]
+				last = end
+			}
+		}
+
+		inner, err := renderShortcodes(inner, e, nil)
+		if err != nil {
+			return "", err
+		}
+
+		out, err := execShortcode(name, params, inner, e)
+		if err != nil {
+			return "", err
+		}
+
+		if ph != nil {
+			out = ph.add(out)
+		}
+
+		result += out
+	}
+
+	return result + content[last:], nil
+}
+
+// closingShortcode is a helper function for renderShortcodes that
+// finds the tag that closes the shortcode with the given name in the
+// given content after the given offset. Tags in the given code ranges
+// are skipped. It returns where the tag starts and ends or -1 if there
+// isn't one.
+func closingShortcode(content string, from int, name string,
+	code [][2]int) (int, int) {
+
+	depth := 0
+	for _, m := range shortcodeRegex.FindAllStringSubmatchIndex(
+		content[from:], -1) {
+		for i := range m {
+			m[i] += from
+		}
+		if content[m[4]:m[5]] != name || inRanges(code, m[0]) {
+			continue
+		}
+
+		switch {
+		case m[3] > m[2] && depth == 0:
+			return m[0], m[1]
+		case m[3] > m[2]:
+			depth--
+		case m[9] == m[8]:
+			depth++
+		}
+	}
+
+	return -1, -1
+}
+
+// execShortcode is a helper function for renderShortcodes that runs
+// the template of the shortcode with the given name.
+func execShortcode(name string, params map[string]string, inner string,
+	e *Entry) (string, error) {
+
+	tmplt, ok := Shortcodes[name]
+	if !ok {
+		return "", fmt.Errorf("unknown shortcode: %s", name)
+	}
+
+	// The markdown function renders the content like the rest of the
+	// entry.
+	tmplt, err := tmplt.Clone()
+	if err != nil {
+		return "", err
+	}
+	tmplt.Funcs(template.FuncMap{
+		"markdown": func(md string) string {
+			return e.renderMarkdown(md, &placeholders{})
+		},
+	})
+
+	data := struct {
+		Params map[string]string
+		Inner  string
+		Entry  *Entry
+		Data   map[string]interface{}
+	}{
+		Params: params,
+		Inner:  inner,
+		Entry:  e,
+		Data:   Data,
+	}
+
+	out, err := ExecTemplate(tmplt, data)
+	if err != nil {
+		return "", fmt.Errorf("shortcode %s: %v", name, err)
+	}
+
+	return strings.TrimSpace(out), nil
+}
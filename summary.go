@@ -0,0 +1,211 @@
+// Copyright 2013 Joshua Marsh. All rights reserved.  Use of this
+// source code is governed by a BSD-style license that can be found in
+// the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// moreRegex matches the marker an author can put into a blog entry
+// to explicitly say where the summary ends.
+var moreRegex = regexp.MustCompile(`<!--[ ]*more[ ]*-->`)
+
+// commentRegex matches HTML comments, like the ones used for the meta
+// data of an entry.
+var commentRegex = regexp.MustCompile(`(?s)<!--.*?-->`)
+
+// tagRegex matches HTML comments and tags within rendered content.
+// The MathML of the math is matched as a whole, so it's never cut and
+// its words aren't counted.
+var tagRegex = regexp.MustCompile(
+	`(?s)<math[\s>].*?</math>|<!--.*?-->|<[^>]*>`)
+
+// annotationRegex matches the LaTeX kept in the MathML of the math.
+var annotationRegex = regexp.MustCompile(
+	`(?s)<annotation[^>]*>(.*?)</annotation>`)
+
+// voidElements are the HTML elements that never have a closing tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true,
+	"hr": true, "img": true, "input": true, "link": true, "meta": true,
+	"param": true, "source": true, "track": true, "wbr": true,
+}
+
+// SplitMore looks for the <!--more--> marker in the given markdown. If
+// it's found and something follows it, the markdown before the marker
+// is returned along with true. Otherwise, nil and false are returned.
+func SplitMore(contents []byte) ([]byte, bool) {
+	loc := moreRegex.FindIndex(contents)
+	if loc == nil || len(bytes.TrimSpace(contents[loc[1]:])) == 0 {
+		return nil, false
+	}
+
+	return contents[:loc[0]], true
+}
+
+// StripComments removes all of the HTML comments from the given
+// content.
+func StripComments(content string) string {
+	return strings.TrimSpace(commentRegex.ReplaceAllString(content, ""))
+}
+
+// PlainText removes all of the tags from the given HTML and unescapes
+// the entities so that only the text a reader would see remains. Math
+// is replaced by its LaTeX.
+func PlainText(content string) string {
+	return html.UnescapeString(tagRegex.ReplaceAllStringFunc(content,
+		func(tag string) string {
+			if m := annotationRegex.FindStringSubmatch(tag); m != nil &&
+				strings.HasPrefix(tag, "<math") {
+				return " " + m[1] + " "
+			}
+			return " "
+		}))
+}
+
+// TruncateHTML shortens the given HTML to at most the given number of
+// words. Tags that are still open at the point where it was cut are
+// closed so the result is safe to put into a page. The second return
+// value is true if anything was removed.
+func TruncateHTML(content string, words int) (string, bool) {
+	if words <= 0 {
+		return content, false
+	}
+
+	buf := new(bytes.Buffer)
+	open := []string{}
+	count := 0
+	last := 0
+
+	// mark is where the last word ended once the limit was reached
+	// with the tags open there. If the limit is reached at the end of
+	// an element, the cut is made there, so the ellipsis goes in it and
+	// not in an empty element after it.
+	mark := -1
+	var markOpen []string
+	cutAt := func(text string) string {
+		if mark >= 0 {
+			buf.Truncate(mark)
+			return closeTags(buf, markOpen)
+		}
+		buf.WriteString(strings.TrimRightFunc(text, unicode.IsSpace))
+		return closeTags(buf, open)
+	}
+
+	for _, loc := range tagRegex.FindAllStringIndex(content, -1) {
+		// Handle the text leading up to this tag.
+		text := content[last:loc[0]]
+		n, cut := cutWords(text, words-count)
+		if cut {
+			return cutAt(text[:n]), true
+		}
+		buf.WriteString(text)
+		count += countWords(text)
+		if mark < 0 && count >= words {
+			mark = buf.Len() - len(text) +
+				len(strings.TrimRightFunc(text, unicode.IsSpace))
+			markOpen = append([]string{}, open...)
+		}
+
+		// Now handle the tag itself.
+		tag := content[loc[0]:loc[1]]
+		buf.WriteString(tag)
+		last = loc[1]
+
+		name, closing := tagName(tag)
+		switch {
+		case name == "math" && strings.HasSuffix(tag, "</math>"):
+			// The whole math element.
+		case name == "" || voidElements[name] ||
+			strings.HasSuffix(tag, "/>"):
+			// Nothing to keep track of.
+		case closing:
+			// Pop back to the matching opening tag.
+			for i := len(open) - 1; i >= 0; i-- {
+				if open[i] == name {
+					open = open[:i]
+					break
+				}
+			}
+		default:
+			open = append(open, name)
+		}
+	}
+
+	// Whatever is left after the last tag.
+	text := content[last:]
+	n, cut := cutWords(text, words-count)
+	if !cut {
+		return content, false
+	}
+
+	return cutAt(text[:n]), true
+}
+
+// closeTags is a helper function for TruncateHTML that adds an
+// ellipsis and closes all of the given open tags in reverse order.
+func closeTags(buf *bytes.Buffer, open []string) string {
+	buf.WriteString("&hellip;")
+	for i := len(open) - 1; i >= 0; i-- {
+		buf.WriteString("</" + open[i] + ">")
+	}
+
+	return buf.String()
+}
+
+// tagName returns the lowercase name of the given tag and whether or
+// not it's a closing tag. Comments and doctypes return "".
+func tagName(tag string) (string, bool) {
+	tag = strings.TrimPrefix(tag, "<")
+	if strings.HasPrefix(tag, "!") || strings.HasPrefix(tag, "?") {
+		return "", false
+	}
+
+	closing := strings.HasPrefix(tag, "/")
+	tag = strings.TrimPrefix(tag, "/")
+
+	end := strings.IndexFunc(tag, func(r rune) bool {
+		return unicode.IsSpace(r) || r == '>' || r == '/'
+	})
+	if end < 0 {
+		end = len(tag)
+	}
+
+	return strings.ToLower(tag[:end]), closing
+}
+
+// cutWords returns the offset in text just after the nth word. If the
+// text has no more than n words, it returns len(text) and false.
+func cutWords(text string, n int) (int, bool) {
+	inWord := false
+	seen := 0
+
+	for i, r := range text {
+		if unicode.IsSpace(r) {
+			inWord = false
+			continue
+		}
+
+		if !inWord {
+			if seen == n {
+				return i, true
+			}
+			seen++
+			inWord = true
+		}
+	}
+
+	return len(text), false
+}
+
+// countWords returns the number of whitespace separated words in the
+// given text.
+func countWords(text string) int {
+	return len(strings.Fields(text))
+}
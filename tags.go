@@ -5,17 +5,256 @@
 package main
 
 import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"os"
+	"path"
 	"sort"
+	"strings"
 )
 
+// TagLevels is the number of levels the Weight of a Tag can be.
+const TagLevels = 5
+
+// TagProfiles are the tags from the TagsFile keyed by their normalized
+// name and the normalized names of their aliases.
+var TagProfiles = map[string]*TagProfile{}
+
+// TagProfile describes a tag. Profiles are loaded from the TagsFile,
+// which is a TOML file with a table for each tag keyed by its name:
+//
+//      [linux]
+//      name = "Linux"
+//      description = "Posts about the Linux operating system."
+//      aliases = ["GNU/Linux", "gnu-linux"]
+type TagProfile struct {
+	// Key is the normalized name of the tag.
+	Key string
+
+	// Name is the name of the tag as it should be displayed.
+	Name string `toml:"name"`
+
+	// Description is a little bit about the tag.
+	Description string `toml:"description"`
+
+	// Aliases are other names that mean the same tag.
+	Aliases []string `toml:"aliases"`
+}
+
+// LoadTags reads the tag profiles from the given TOML file and keys
+// them by their normalized name and aliases. A name or alias can only
+// belong to one tag. It's fine if the file doesn't exist.
+func LoadTags(file string) (map[string]*TagProfile, error) {
+	tags := map[string]*TagProfile{}
+
+	_, err := toml.DecodeFile(file, &tags)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return tags, nil
+		}
+		return nil, err
+	}
+
+	// Go through them in order so the errors are always the same.
+	names := []string{}
+	for name := range tags {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	// Key the profiles by their normalized name.
+	profiles := map[string]*TagProfile{}
+	for _, name := range names {
+		tp := tags[name]
+		tp.Key = NormalizeTag(name)
+		if tp.Name == "" {
+			tp.Name = name
+		}
+
+		if other, ok := profiles[tp.Key]; ok {
+			return nil, fmt.Errorf("tags %q and %q are the same",
+				other.Name, tp.Name)
+		}
+		profiles[tp.Key] = tp
+	}
+
+	// Then by their aliases.
+	for _, name := range names {
+		tp := tags[name]
+		for _, alias := range tp.Aliases {
+			key := NormalizeTag(alias)
+			if other, ok := profiles[key]; ok && other != tp {
+				return nil, fmt.Errorf("alias %q of tag %q is also %q",
+					alias, tp.Name, other.Name)
+			}
+			profiles[key] = tp
+		}
+	}
+
+	return profiles, nil
+}
+
+// NormalizeTag returns the name of the given tag in a form that is
+// the same no matter how it's capitalized or spaced.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.Join(strings.Fields(tag), " "))
+}
+
+// ResolveTag finds the profile for the given tag. It can be the name
+// of a tag in the TagProfiles or one of its aliases. Tags without a
+// profile get one with just their name.
+func ResolveTag(tag string) *TagProfile {
+	return resolveTerm(TagProfiles, tag)
+}
+
+// ResolveTags returns the display names of the given tags. Tags that
+// resolve to the same tag are only returned once.
+func ResolveTags(tags []string) []string {
+	return resolveTerms(TagProfiles, tags)
+}
+
+// resolveTerm finds the profile for the given term of a taxonomy in
+// the given profiles, which are keyed by both names and aliases (see
+// LoadTags). Terms without a profile get one with just their name.
+func resolveTerm(profiles map[string]*TagProfile, term string) *TagProfile {
+	key := NormalizeTag(term)
+
+	if tp, ok := profiles[key]; ok {
+		return tp
+	}
+
+	return &TagProfile{Key: key, Name: strings.TrimSpace(term)}
+}
+
+// resolveTerms returns the display names of the given terms of a
+// taxonomy. Terms that resolve to the same one are only returned once.
+func resolveTerms(profiles map[string]*TagProfile, terms []string) []string {
+	names := []string{}
+	seen := map[string]bool{}
+
+	for _, term := range terms {
+		tp := resolveTerm(profiles, term)
+		if tp.Key == "" || seen[tp.Key] {
+			continue
+		}
+
+		seen[tp.Key] = true
+		names = append(names, tp.Name)
+	}
+
+	return names
+}
+
+// taxonomyProfiles returns the profiles of the terms of the given
+// taxonomy. Only tags have profiles.
+func taxonomyProfiles(taxonomy string) map[string]*TagProfile {
+	if taxonomy == "tags" {
+		return TagProfiles
+	}
+
+	return nil
+}
+
+// reservedTaxonomies are the names of the pages, directories and
+// templates the site uses for itself.
+var reservedTaxonomies = []string{"about", "archive", "archives",
+	"author", "authors", "entries", "entry", "feed", "galleries",
+	"gallery", "gone", "index", "photo", "series", "site", "tags",
+	thumbnailDir, "updated"}
+
+// reservedNames returns the reservedTaxonomies along with the names of
+// the GalleryDir and the directories of the SiteLanguages.
+func reservedNames() []string {
+	reserved := []string{path.Base(GalleryDir)}
+	reserved = append(reserved, reservedTaxonomies...)
+	return append(reserved, SiteLanguages...)
+}
+
+// CheckTaxonomy returns an error if the pages or templates of the given
+// taxonomy would have the same name as the ones the site already uses,
+// including those of the GalleryDir, the Sections and the
+// SiteLanguages.
+func CheckTaxonomy(taxonomy string) error {
+	reserved := reservedNames()
+	for _, s := range Sections {
+		reserved = append(reserved, s.Name)
+		reserved = append(reserved, s.templates()...)
+	}
+
+	for _, name := range reserved {
+		if name == taxonomy || name == taxonomy+"-term" {
+			return fmt.Errorf("reserved taxonomy name: %s", taxonomy)
+		}
+	}
+
+	return nil
+}
+
+// TaxonomyKey returns the name of the meta data that contains the
+// terms of the given taxonomy (e.g. Categories for categories).
+func TaxonomyKey(taxonomy string) string {
+	if taxonomy == "" {
+		return ""
+	}
+
+	return strings.ToUpper(taxonomy[:1]) + taxonomy[1:]
+}
+
 // Tag is a representation of a tag and it's associated entries. It is
-// used as a storage mechanism for the tags page.
+// used as a storage mechanism for the tags page. The terms of the
+// other Taxonomies are Tags as well.
 type Tag struct {
 	// The name of the Tag.
 	Name string
 
+	// The normalized name of the Tag.
+	Key string
+
+	// The name of the Tag as it's used for urls.
+	Slug string
+
+	// The taxonomy the Tag belongs to (e.g. tags).
+	Taxonomy string
+
+	// A little bit about the Tag.
+	Description string
+
+	// How often the Tag is used compared to the others from 1 to
+	// TagLevels. It's useful for tag clouds.
+	Weight int
+
 	// The list of Entries associated with this tag.
 	Entries []*Entry
+
+	// The total number of words in the Entries.
+	WordCount int
+
+	// The total number of minutes it takes to read the Entries.
+	ReadingTime int
+}
+
+// Url returns the url of the Tag's page relative to the root of the
+// site in the page's language.
+func (t *Tag) Url() string {
+	return t.Taxonomy + "/" + t.Slug + ".html"
+}
+
+// FeedUrl returns the url of the Tag's RSS feed relative to the root
+// of the site in the page's language.
+func (t *Tag) FeedUrl() string {
+	return t.Taxonomy + "/" + t.Slug + ".rss"
+}
+
+// languages returns the languages the Tag has entries in.
+func (t *Tag) languages() []string {
+	return versionLanguages(t.Entries, func(e *Entry) bool {
+		for _, term := range e.TermsOf(t.Taxonomy) {
+			if resolveTerm(taxonomyProfiles(t.Taxonomy), term).Key == t.Key {
+				return true
+			}
+		}
+		return false
+	})
 }
 
 // Add links the given Entry to this Tag.
@@ -26,6 +265,8 @@ func (t *Tag) Add(e *Entry) {
 	}
 
 	t.Entries = append(t.Entries, e)
+	t.WordCount += e.WordCount
+	t.ReadingTime = ReadingTime(t.WordCount)
 }
 
 // Tags is a map of Tags structures with some methods for easily
@@ -34,29 +275,84 @@ func (t *Tag) Add(e *Entry) {
 type Tags map[string]*Tag
 
 // GetTags builds a Tags from the given list of blogs.
-func GetTags(entries []*Entry) Tags {
+func GetTags(entries []*Entry) (Tags, error) {
+	return GetTerms(entries, "tags")
+}
+
+// GetTerms builds a Tags from the terms of the given taxonomy in the
+// given list of blogs.
+func GetTerms(entries []*Entry, taxonomy string) (Tags, error) {
 	t := make(Tags)
 
 	for _, blog := range entries {
-		t.Add(blog)
+		err := t.Add(taxonomy, blog)
+		if err != nil {
+			return nil, err
+		}
 	}
 
-	return t
+	t.weigh()
+
+	return t, nil
 }
 
-// Add links the given Entry to all of it's tags.
-func (t Tags) Add(e *Entry) {
-	for _, tag := range e.Tags {
-		f, ok := t[tag]
+// Add links the given Entry to all of it's terms in the given
+// taxonomy.
+func (t Tags) Add(taxonomy string, e *Entry) error {
+	for _, term := range e.TermsOf(taxonomy) {
+		tp := resolveTerm(taxonomyProfiles(taxonomy), term)
+		f, ok := t[tp.Key]
 		if !ok {
 			// It wasn't found, so create one.
-			t[tag] = &Tag{
-				Name:    tag,
-				Entries: []*Entry{e},
+			slug, err := MakeBlogName(tp.Key)
+			if err != nil {
+				return err
 			}
-		} else {
-			// Add it to the one we found.
-			f.Add(e)
+
+			// Different terms can't share a page.
+			for _, other := range t {
+				if other.Slug == slug {
+					return fmt.Errorf("%s %q and %q have the same url: %s",
+						taxonomy, other.Name, tp.Name, slug)
+				}
+			}
+
+			f = &Tag{
+				Name:        tp.Name,
+				Key:         tp.Key,
+				Slug:        slug,
+				Taxonomy:    taxonomy,
+				Description: tp.Description,
+				Entries:     []*Entry{},
+			}
+			t[tp.Key] = f
+		}
+
+		// Add it to the one we found.
+		f.Add(e)
+	}
+
+	return nil
+}
+
+// weigh sets the Weight of each tag by spreading the number of entries
+// they have evenly across the TagLevels.
+func (t Tags) weigh() {
+	min, max := -1, 0
+	for _, tag := range t {
+		if min == -1 || len(tag.Entries) < min {
+			min = len(tag.Entries)
+		}
+		if len(tag.Entries) > max {
+			max = len(tag.Entries)
+		}
+	}
+
+	for _, tag := range t {
+		tag.Weight = 1
+		if max > min {
+			tag.Weight += (len(tag.Entries) - min) * (TagLevels - 1) /
+				(max - min)
 		}
 	}
 }
@@ -88,7 +384,7 @@ func (t TagSlice) Len() int {
 
 // Less returns true if the value at i is less than the value at j.
 func (t TagSlice) Less(i, j int) bool {
-	return t[i].Name < t[j].Name
+	return t[i].Key < t[j].Key
 }
 
 // Swap switches the elemens at i and j.
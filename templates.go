@@ -7,10 +7,13 @@ package main
 import (
 	"bytes"
 	"fmt"
+	"github.com/russross/blackfriday"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path"
+	"reflect"
+	"strings"
 	"text/template"
 	"time"
 )
@@ -18,6 +21,83 @@ import (
 // Templates is a set of goblog templates.
 type Templates map[string]*template.Template
 
+// templateFuncs are the functions available in every template. T is
+// replaced with the one for the right language by ForLanguage.
+var templateFuncs = template.FuncMap{
+	"asset":    Asset,
+	"sortBy":   sortBy,
+	"markdown": markdown,
+	"T": func(key string, args ...interface{}) string {
+		return GetLocale(DefaultLanguage()).T(key, args...)
+	},
+}
+
+// markdown is the template function that converts the given markdown
+// to HTML. The math in it is rendered too (see RenderMath).
+func markdown(md string) string {
+	ph := &placeholders{}
+	md = RenderMath(md, ph)
+	return ph.restore(string(blackfriday.MarkdownCommon([]byte(md))))
+}
+
+// sortBy is the template function that sorts a list of entries by the
+// given order (e.g. {{range sortBy "title" .Term.Entries}}). See
+// SortEntries for the orders. The list can also be one whose items
+// contain an entry, like the .Entries of entries.html.
+func sortBy(by string, list interface{}) (interface{}, error) {
+	v := reflect.ValueOf(list)
+	if v.Kind() != reflect.Slice {
+		return nil, fmt.Errorf("sortBy: not a list: %T", list)
+	}
+
+	// Find the entry of each item.
+	items := map[*Entry]reflect.Value{}
+	entries := []*Entry{}
+	for i := 0; i < v.Len(); i++ {
+		item := v.Index(i)
+		e, ok := item.Interface().(*Entry)
+		if !ok && item.Kind() == reflect.Struct {
+			e, ok = item.FieldByName("Entry").Interface().(*Entry)
+		}
+		if !ok {
+			return nil, fmt.Errorf("sortBy: not an entry: %s", item.Type())
+		}
+
+		items[e] = item
+		entries = append(entries, e)
+	}
+
+	sorted, err := SortEntries(entries, by)
+	if err != nil {
+		return nil, err
+	}
+
+	result := reflect.MakeSlice(v.Type(), 0, len(sorted))
+	for _, e := range sorted {
+		result = reflect.Append(result, items[e])
+	}
+
+	return result.Interface(), nil
+}
+
+// ForLanguage returns a copy of the templates whose T function
+// translates into the given language.
+func (t Templates) ForLanguage(lang string) (Templates, error) {
+	ret := make(Templates)
+	l := GetLocale(lang)
+
+	for name, tmplt := range t {
+		c, err := tmplt.Clone()
+		if err != nil {
+			return nil, err
+		}
+
+		ret[name] = c.Funcs(template.FuncMap{"T": l.T})
+	}
+
+	return ret, nil
+}
+
 // SiteData is a struct that contains all of the information necessary
 // for generating a site page.
 type SiteData struct {
@@ -27,6 +107,9 @@ type SiteData struct {
 	Author      string
 	Content     string
 	Languages   []string
+	Language    string
+	Alternates  []*Alternate
+	SEO         *SEO
 	AtHome      bool
 	AtTags      bool
 	AtArchives  bool
@@ -34,8 +117,26 @@ type SiteData struct {
 }
 
 // Helper is included with each template data. It allows the methods
-// associated with this value to be run within the template.
-type Helper bool
+// associated with this value to be run within the template. Its
+// values help link to other pages no matter how deep the current page
+// is within the site.
+type Helper struct {
+	// Root is the relative path from the page to the root of the site
+	// (e.g. "../"). It's "" for pages in the root of the site. Use it
+	// for static assets.
+	Root string
+
+	// LanguageRoot is the relative path from the page to the root of
+	// the site in the page's language. Use it for links to other
+	// pages (e.g. {{.LanguageRoot}}tags.html).
+	LanguageRoot string
+}
+
+// Data returns the contents of the data files (see LoadData). For
+// example, {{range .Data.blogroll}} or {{index .Data.talks "2013"}}.
+func (h Helper) Data() map[string]interface{} {
+	return Data
+}
 
 // Exec runs the given command and returns the combined output.
 func (h Helper) Exec(name string, args ...string) (string, error) {
@@ -54,12 +155,16 @@ func (h Helper) Exec(name string, args ...string) (string, error) {
 // calling MakeWebPage.
 func (t Templates) MakeAbout(dir string) error {
 
+	file := path.Join(dir, "about.html")
+
 	// Make the data that will be passed to the templater.
 	data := struct {
 		Helper
 		CDate string
 	}{
-		CDate: time.Now().Format("2006-01-02"),
+		Helper: NewHelper(file),
+		CDate:  pageLocale(file).Format(time.Now().In(Location),
+			pageLocale(file).DateFormat),
 	}
 
 	// Perform the templating
@@ -69,8 +174,8 @@ func (t Templates) MakeAbout(dir string) error {
 	}
 
 	// Make the pages with the siteData Helper Function
-	return t.MakeWebPage(path.Join(dir, "about.html"), &SiteData{
-		Title:      "About",
+	return t.MakeWebPage(file, &SiteData{
+		Title:      pageLocale(file).T("About"),
 		Content:    content,
 		AtHome:     false,
 		AtTags:     false,
@@ -88,45 +193,234 @@ func (t Templates) MakeAbout(dir string) error {
 //      .Years   - A slice of Years that contain blog entries. Each one
 //	               contains:
 //        .Year   - The name of the Year (e.g. 2013).
+//        .Url    - The url of the year's page. See MakeArchiveYear.
+//        .WordCount   - The total number of words written that year.
+//        .ReadingTime - The total minutes to read that year's entries.
 //        .Months - A slice of months for this year that contains blog
 //                  entries. Each one contains:
 //          .Month   - The name of the month (e.g. January).
+//          .Url     - The url of the month's page. See
+//                     MakeArchiveMonth.
 //          .Entries - A slice of blog entries for the given month of
 //                     the given year. Each one contains:
 //            .CDate   - The date of the blog entry.
 //            .Url     - The url of the blog entry.
 //            .Title   - The title of the blog entry.
+//            .Thumbnail - The small version of the featured image
+//                       or nil. It contains a .Url, .Width and
+//                       .Height.
 //
 // The results of that templating are then used as the content for
 // calling MakeWebPage.
 func (t Templates) MakeArchive(dir string, a []*YearEntries) error {
+	file := path.Join(dir, "archives.html")
+
+	return t.makeArchive(file, t["archive"], a, &SiteData{
+		Title:      pageLocale(file).T("Archives"),
+		AtHome:     false,
+		AtTags:     false,
+		AtArchives: true,
+		AtAbout:    false,
+	})
+}
+
+// MakeSectionArchive creates a completed archives HTML page for the
+// entries of the given section and puts it into the section's
+// directory in the given directory (e.g. notes/archives.html). It uses
+// the section's ArchiveTemplate and fills in the same values as
+// MakeArchive. If the section doesn't have an ArchiveTemplate, nothing
+// is done.
+func (t Templates) MakeSectionArchive(dir string, s *Section,
+	a []*YearEntries) error {
+
+	tmplt, ok := t[s.ArchiveTemplate]
+	if !ok {
+		return nil
+	}
+
+	file := path.Join(dir, s.ArchiveUrl())
+	err := MakeDirIfNotExists(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	return t.makeArchive(file, tmplt, a, &SiteData{
+		Title:      s.Title + " " + pageLocale(file).T("Archives"),
+		Alternates: languageAlternates(file, sectionLanguages(a)),
+		AtArchives: true,
+	})
+}
+
+// sectionLanguages is a helper function for MakeSectionArchive that
+// returns the languages that have entries in the given archives.
+func sectionLanguages(a []*YearEntries) []string {
+	langs := map[string]bool{}
+	for _, y := range a {
+		for _, lang := range y.languages() {
+			langs[lang] = true
+		}
+	}
+
+	result := []string{}
+	for _, lang := range SiteLanguages {
+		if langs[lang] {
+			result = append(result, lang)
+		}
+	}
+
+	return result
+}
+
+// makeArchive is a helper function for MakeArchive and
+// MakeSectionArchive that renders the given archives with the given
+// template and writes the page to the given file.
+func (t Templates) makeArchive(file string, tmplt *template.Template,
+	a []*YearEntries, sd *SiteData) error {
+
+	// Make the data that will be passed to the templater.
+	data := struct {
+		Helper
+		Section *Section
+		Years   []*YearEntries
+		CDate   string
+	}{
+		Helper:  NewHelper(file),
+		Section: GetSection(pageSection(file)),
+		Years:   a,
+		CDate: pageLocale(file).Format(time.Now().In(Location),
+			pageLocale(file).DateFormat),
+	}
+
+	// Perform the templating
+	content, err := ExecTemplate(tmplt, data)
+	if err != nil {
+		return err
+	}
+
+	// Make the pages with the siteData Helper Function
+	sd.Content = content
+	return t.MakeWebPage(file, sd)
+}
+
+// MakeArchiveYear creates a completed HTML page for the given year and
+// puts it into the archive directory of the given directory (e.g.
+// archive/2013/index.html). It uses the template from archive-year.html
+// and will fill in the following values:
+//
+//      .CDate - The date the page was created.
+//      .Year  - The year. It's the same as one of the Years in
+//               MakeArchive and has a .Url to its page. Each of its
+//               .Months also has a .Url to its page.
+//
+// The results of that templating are then used as the content for
+// calling MakeWebPage. If there is no archive-year.html, the page is
+// made with archive.html like MakeArchive but .Years only has the
+// year.
+func (t Templates) MakeArchiveYear(dir string, y *YearEntries) error {
+	file := path.Join(dir, y.Url())
+	err := MakeDirIfNotExists(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	sd := &SiteData{
+		Title:      y.Year,
+		Alternates: languageAlternates(file, y.languages()),
+		AtArchives: true,
+	}
+
+	tmplt, ok := t["archive-year"]
+	if !ok {
+		return t.makeArchive(file, t["archive"], []*YearEntries{y}, sd)
+	}
 
 	// Make the data that will be passed to the templater.
 	data := struct {
 		Helper
-		Years []*YearEntries
+		Year  *YearEntries
 		CDate string
 	}{
-		Years: a,
-		CDate: time.Now().Format("2006-01-02"),
+		Helper: NewHelper(file),
+		Year:   y,
+		CDate: pageLocale(file).Format(time.Now().In(Location),
+			pageLocale(file).DateFormat),
 	}
 
 	// Perform the templating
-	content, err := ExecTemplate(t["archive"], data)
+	content, err := ExecTemplate(tmplt, data)
 	if err != nil {
 		return err
 	}
 
 	// Make the pages with the siteData Helper Function
-	return t.MakeWebPage(path.Join(dir, "archives.html"), &SiteData{
-		Title:      "Archives",
-		Content:    content,
-		AtHome:     false,
-		AtTags:     false,
+	sd.Content = content
+	return t.MakeWebPage(file, sd)
+}
+
+// MakeArchiveMonth creates a completed HTML page for the given month
+// and puts it into the archive directory of the given directory (e.g.
+// archive/2013/07/index.html). It uses the template from
+// archive-month.html and will fill in the following values:
+//
+//      .CDate - The date the page was created.
+//      .Month - The month. It contains:
+//        .Month   - The name of the month (e.g. January).
+//        .Year    - The year the month is in (e.g. 2013).
+//        .Number  - The number of the month (e.g. 07).
+//        .Title   - The name of the month and its year.
+//        .Url     - The url of the month's page.
+//        .Entries - A slice of blog entries for the month, newest
+//                   first.
+//
+// The results of that templating are then used as the content for
+// calling MakeWebPage. If there is no archive-month.html, the page is
+// made with archive.html like MakeArchive but .Years only has the
+// month's year with just the month in it.
+func (t Templates) MakeArchiveMonth(dir string, m *MonthEntries) error {
+	file := path.Join(dir, m.Url())
+	err := MakeDirIfNotExists(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	sd := &SiteData{
+		Title:      m.Title,
+		Alternates: languageAlternates(file, m.languages()),
 		AtArchives: true,
-		AtAbout:    false,
-	})
+	}
 
+	tmplt, ok := t["archive-month"]
+	if !ok {
+		y := &YearEntries{Year: m.Year, Months: []*MonthEntries{m}}
+		for _, e := range m.Entries {
+			y.WordCount += e.WordCount
+		}
+		y.ReadingTime = ReadingTime(y.WordCount)
+
+		return t.makeArchive(file, t["archive"], []*YearEntries{y}, sd)
+	}
+
+	// Make the data that will be passed to the templater.
+	data := struct {
+		Helper
+		Month *MonthEntries
+		CDate string
+	}{
+		Helper: NewHelper(file),
+		Month:  m,
+		CDate: pageLocale(file).Format(time.Now().In(Location),
+			pageLocale(file).DateFormat),
+	}
+
+	// Perform the templating
+	content, err := ExecTemplate(tmplt, data)
+	if err != nil {
+		return err
+	}
+
+	// Make the pages with the siteData Helper Function
+	sd.Content = content
+	return t.MakeWebPage(file, sd)
 }
 
 // MakeIndex creates a completed index HTML page and puts it into the
@@ -140,20 +434,102 @@ func (t Templates) MakeArchive(dir string, a []*YearEntries) error {
 //                   creation, this will be the most recent update
 //                   date.
 //        .Content - The HTML formated Content of blog entry.
+//        .Summary - The HTML formated summary of the blog entry.
+//        .Truncated - If true, the summary isn't the whole entry
+//                   and you may want to link to the rest of it.
+//        .WordCount   - The number of words in the entry.
+//        .ReadingTime - The estimated minutes it takes to read it.
 //        .Tags    - A list of tags (strings) for the blog entry.
+//        .Image   - The url of the featured image or "".
+//        .ImageAlt - The description of the featured image.
+//        .Thumbnail - The small version of the featured image or nil.
+//                   It contains a .Url, .Width and .Height.
+//        .Pinned  - If true, the entry is pinned to the top.
+//        .Weight  - The weight of the entry.
+//
+// The entries are sorted by the IndexSort with the pinned ones first.
 //
 // The results of that templating are then used as the content for
 // calling MakeWebPage.
 func (t Templates) MakeIndex(dir string, b []*Entry) error {
+	file := path.Join(dir, "index.html")
+
+	return t.makeList(file, t["entries"], b, &SiteData{
+		Title:      pageLocale(file).T("Index"),
+		AtHome:     true,
+		AtTags:     false,
+		AtArchives: false,
+		AtAbout:    false,
+	})
+}
+
+// MakeUpdated creates a completed HTML page listing the given
+// entries, which should be the most recently updated ones, and puts it
+// into the given directory as updated.html. It uses the template from
+// updated.html and fills in the same values as MakeIndex. If there is
+// no updated.html, nothing is done.
+func (t Templates) MakeUpdated(dir string, b []*Entry) error {
+	tmplt, ok := t["updated"]
+	if !ok {
+		return nil
+	}
+
+	file := path.Join(dir, "updated.html")
+
+	return t.makeList(file, tmplt, b, &SiteData{
+		Title: pageLocale(file).T("Recently Updated"),
+	})
+}
+
+// MakeSection creates a completed HTML page listing the given entries
+// of the given section and puts it into the section's directory in the
+// given directory (e.g. notes/index.html). It uses the section's
+// ListTemplate and fills in the same values as MakeIndex plus:
+//
+//      .Section - The section. It contains:
+//        .Name  - The name of the section.
+//        .Title - The title of the section.
+//        .ArchiveUrl - The url of the section's archive.
+//
+// If the section doesn't have a ListTemplate, nothing is done.
+func (t Templates) MakeSection(dir string, s *Section, b []*Entry) error {
+	tmplt, ok := t[s.ListTemplate]
+	if !ok {
+		return nil
+	}
+
+	file := path.Join(dir, s.Url())
+	err := MakeDirIfNotExists(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	return t.makeList(file, tmplt, b, &SiteData{
+		Title: s.Title,
+		Alternates: languageAlternates(file,
+			versionLanguages(b, func(e *Entry) bool {
+				return e.Section == s.Name
+			})),
+	})
+}
+
+// makeList is a helper function for MakeIndex and MakeSection that
+// renders the given entries with the given template and writes the
+// page to the given file.
+func (t Templates) makeList(file string, tmplt *template.Template,
+	b []*Entry, sd *SiteData) error {
 
 	// Make the HTML for each entry.
 	entries := struct {
 		Helper
+		Section *Section
 		Entries []struct {
 			*Entry
 			Content string
 		}
 	}{
+		Helper:  NewHelper(file),
+		Section: GetSection(pageSection(file)),
 		Entries: []struct {
 			*Entry
 			Content string
@@ -163,11 +539,12 @@ func (t Templates) MakeIndex(dir string, b []*Entry) error {
 	// Generate the entries list.
 	languages := []string{}
 	for _, blog := range b {
-		// Make the content.
-		c, err := blog.Parse()
-		if err != nil {
-			fmt.Println("parsing blog", blog, ":", err)
-			os.Exit(1)
+		// Use the content made when it was parsed.
+		c := blog.content
+
+		// Only the page of a protected entry has its content.
+		if blog.Protected() {
+			c = ""
 		}
 
 		// Store the languages.
@@ -190,22 +567,15 @@ func (t Templates) MakeIndex(dir string, b []*Entry) error {
 	languages = removeDuplicates(languages)
 
 	// Perform the templating
-	content, err := ExecTemplate(t["entries"], entries)
+	content, err := ExecTemplate(tmplt, entries)
 	if err != nil {
 		return err
 	}
 
 	// Make the pages with the siteData Helper Function
-	return t.MakeWebPage(path.Join(dir, "index.html"), &SiteData{
-		Title:      "Index",
-		Content:    content,
-		Languages:  languages,
-		AtHome:     true,
-		AtTags:     false,
-		AtArchives: false,
-		AtAbout:    false,
-	})
-
+	sd.Content = content
+	sd.Languages = languages
+	return t.MakeWebPage(file, sd)
 }
 
 // removeDuplicates is a helper function for the MakeIndex page. It
@@ -229,43 +599,437 @@ func removeDuplicates(a []string) []string {
 //      .CDate - The date the page was created.
 //      .Tags - A list of tags for the blog entry. Each one contains:
 //         .Name - The name of the tag.
+//         .Url  - The url of the tag's page. See MakeTerm.
+//         .FeedUrl - The url of the tag's RSS feed.
+//         .Key  - The normalized name of the tag. It can contain
+//                 spaces.
+//         .Slug - The name of the tag for use in urls and anchors.
+//         .Description - A little bit about the tag.
+//         .Weight - How often the tag is used compared to the others
+//                   from 1 to 5. It's useful for tag clouds.
+//         .WordCount   - The total number of words in the tag's entries.
+//         .ReadingTime - The total minutes to read the tag's entries.
 //         .Entries - A slice of blog entries for with the given tag.
 //                    Each one contains:
 //            .Url   - The url of the blog entry.
 //            .Title - The title of the blog entry.
+//            .Thumbnail - The small version of the featured image or
+//                     nil. It contains a .Url, .Width and .Height.
 //
 // The results of that templating are then used as the content for
 // calling MakeWebPage.
 func (t Templates) MakeTags(dir string, ta []*Tag) error {
+	return t.MakeTaxonomy(dir, "tags", ta)
+}
+
+// MakeTaxonomy creates a completed HTML page listing the terms of the
+// given taxonomy (e.g. categories.html) and puts it into the given
+// directory. It uses the template with the same name as the taxonomy
+// and fills in the same values as MakeTags. The terms are in both
+// .Tags and .Terms and the name of the taxonomy is in .Taxonomy.
+//
+// The results of that templating are then used as the content for
+// calling MakeWebPage. If there is no template, nothing is done.
+func (t Templates) MakeTaxonomy(dir, taxonomy string, ta []*Tag) error {
+	tmplt, ok := t[taxonomy]
+	if !ok {
+		return nil
+	}
+
+	file := path.Join(dir, taxonomy+".html")
 
 	// Make the data that will be passed to the templater.
 	data := struct {
 		Helper
-		Tags  []*Tag
-		CDate string
+		Taxonomy string
+		Tags     []*Tag
+		Terms    []*Tag
+		CDate    string
 	}{
-		Tags:  ta,
-		CDate: time.Now().Format("2006-01-02"),
+		Helper:   NewHelper(file),
+		Taxonomy: taxonomy,
+		Tags:     ta,
+		Terms:    ta,
+		CDate: pageLocale(file).Format(time.Now().In(Location),
+			pageLocale(file).DateFormat),
 	}
 
 	// Perform the templating
-	content, err := ExecTemplate(t["tags"], data)
+	content, err := ExecTemplate(tmplt, data)
 	if err != nil {
 		return err
 	}
 
 	// Make the pages with the siteData Helper Function
-	return t.MakeWebPage(path.Join(dir, "tags.html"), &SiteData{
-		Title:      "Tags",
+	return t.MakeWebPage(file, &SiteData{
+		Title:      pageLocale(file).T(TaxonomyKey(taxonomy)),
 		Content:    content,
 		AtHome:     false,
-		AtTags:     true,
+		AtTags:     taxonomy == "tags",
 		AtArchives: false,
 		AtAbout:    false,
 	})
 
 }
 
+// MakeTerm creates a completed HTML page for the given term of a
+// taxonomy and puts it into the directory of the taxonomy in the given
+// directory (e.g. tags/linux.html). It uses the template named after
+// the taxonomy followed by -term (e.g. tags-term.html) and will fill
+// in the following values:
+//
+//      .CDate - The date the page was created.
+//      .Term  - The term. It's the same as one of the Tags in MakeTags
+//               except its .Entries are newest first.
+//
+// The results of that templating are then used as the content for
+// calling MakeWebPage. If there is no template, nothing is done.
+func (t Templates) MakeTerm(dir string, term *Tag) error {
+	tmplt, ok := t[term.Taxonomy+"-term"]
+	if !ok {
+		return nil
+	}
+
+	file := path.Join(dir, term.Url())
+	err := MakeDirIfNotExists(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	// Make the data that will be passed to the templater.
+	data := struct {
+		Helper
+		Term  *Tag
+		CDate string
+	}{
+		Helper: NewHelper(file),
+		Term:   term,
+		CDate: pageLocale(file).Format(time.Now().In(Location),
+			pageLocale(file).DateFormat),
+	}
+
+	// Perform the templating
+	content, err := ExecTemplate(tmplt, data)
+	if err != nil {
+		return err
+	}
+
+	// Make the pages with the siteData Helper Function
+	return t.MakeWebPage(file, &SiteData{
+		Title:       term.Name,
+		Description: term.Description,
+		Content:     content,
+		Alternates:  languageAlternates(file, term.languages()),
+		AtTags:      term.Taxonomy == "tags",
+	})
+}
+
+// MakeAuthor creates a completed HTML page for the given author and
+// puts it into the authors directory of the given directory. It uses
+// the template from author.html and will fill in the following values:
+//
+//      .CDate  - The date the page was created.
+//      .Author - The author. It contains:
+//        .ID      - The ID of the author.
+//        .Name    - The name of the author.
+//        .Bio     - A little bit about the author.
+//        .Avatar  - The url of a picture of the author relative to
+//                   the root of the site.
+//        .Links   - A map of the author's links keyed by their name.
+//        .FeedUrl - The url of the author's RSS feed.
+//        .Entries - A slice of the blog entries the author wrote,
+//                   newest first.
+//
+// The results of that templating are then used as the content for
+// calling MakeWebPage. If there is no author.html, nothing is done.
+func (t Templates) MakeAuthor(dir string, a *AuthorEntries) error {
+	tmplt, ok := t["author"]
+	if !ok {
+		return nil
+	}
+
+	file := path.Join(dir, a.Url())
+	err := MakeDirIfNotExists(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	// Make the data that will be passed to the templater.
+	data := struct {
+		Helper
+		Author *AuthorEntries
+		CDate  string
+	}{
+		Helper: NewHelper(file),
+		Author: a,
+		CDate: pageLocale(file).Format(time.Now().In(Location),
+			pageLocale(file).DateFormat),
+	}
+
+	// Perform the templating
+	content, err := ExecTemplate(tmplt, data)
+	if err != nil {
+		return err
+	}
+
+	// Make the pages with the siteData Helper Function
+	return t.MakeWebPage(file, &SiteData{
+		Title:       a.Name,
+		Description: a.Bio,
+		Author:      a.Name,
+		Content:     content,
+		Alternates:  languageAlternates(file, a.languages()),
+	})
+}
+
+// MakeSeries creates a completed HTML page for the given series and
+// puts it into the series directory of the given directory. It uses
+// the template from series.html and will fill in the following values:
+//
+//      .CDate  - The date the page was created.
+//      .Series - The series. It contains:
+//        .Name  - The name of the series.
+//        .Slug  - The name of the series as it's used for urls.
+//        .Parts - A slice of the blog entries in the series in the
+//                 order they should be read.
+//
+// The results of that templating are then used as the content for
+// calling MakeWebPage. If there is no series.html, nothing is done.
+func (t Templates) MakeSeries(dir string, s *Series) error {
+	tmplt, ok := t["series"]
+	if !ok {
+		return nil
+	}
+
+	file := path.Join(dir, s.Url())
+	err := MakeDirIfNotExists(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	// Make the data that will be passed to the templater.
+	data := struct {
+		Helper
+		Series *Series
+		CDate  string
+	}{
+		Helper: NewHelper(file),
+		Series: s,
+		CDate: pageLocale(file).Format(time.Now().In(Location),
+			pageLocale(file).DateFormat),
+	}
+
+	// Perform the templating
+	content, err := ExecTemplate(tmplt, data)
+	if err != nil {
+		return err
+	}
+
+	// Make the pages with the siteData Helper Function
+	return t.MakeWebPage(file, &SiteData{
+		Title:      s.Name,
+		Content:    content,
+		Alternates: languageAlternates(file, s.languages()),
+	})
+}
+
+// MakeGalleries creates a completed HTML page listing the photo
+// galleries and puts it into the galleries directory of the given
+// directory. It uses the template from galleries.html and will fill in
+// the following values:
+//
+//      .CDate     - The date the page was created.
+//      .Galleries - A slice of the galleries. See MakeGallery for what
+//                   each one contains.
+//
+// The results of that templating are then used as the content for
+// calling MakeWebPage. If there is no galleries.html, nothing is done.
+func (t Templates) MakeGalleries(dir string, gs []*Gallery) error {
+	tmplt, ok := t["galleries"]
+	if !ok || len(gs) == 0 {
+		return nil
+	}
+
+	file := path.Join(dir, path.Base(GalleryDir), "index.html")
+	err := MakeDirIfNotExists(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	// Make the data that will be passed to the templater.
+	data := struct {
+		Helper
+		Galleries []*Gallery
+		CDate     string
+	}{
+		Helper:    NewHelper(file),
+		Galleries: gs,
+		CDate: pageLocale(file).Format(time.Now().In(Location),
+			pageLocale(file).DateFormat),
+	}
+
+	// Perform the templating
+	content, err := ExecTemplate(tmplt, data)
+	if err != nil {
+		return err
+	}
+
+	// Make the pages with the siteData Helper Function
+	return t.MakeWebPage(file, &SiteData{
+		Title:   pageLocale(file).T("Galleries"),
+		Content: content,
+	})
+}
+
+// MakeGallery creates a completed HTML page for the given photo gallery
+// and puts it into the galleries directory of the given directory. It
+// uses the template from gallery.html and will fill in the following
+// values:
+//
+//      .CDate   - The date the page was created.
+//      .Gallery - The gallery. It contains:
+//        .Name        - The name of the gallery's directory.
+//        .Title       - The title of the gallery.
+//        .Description - A little bit about the gallery.
+//        .Url         - The url of the gallery's page.
+//        .Cover       - The first photo of the gallery.
+//        .Photos      - A slice of the photos in the gallery. See
+//                       MakePhoto for what each one contains.
+//
+// The urls of the images are relative to the root of the site, so use
+// {{$.Root}}{{.Thumbnail.Url}}. The results of that templating are then
+// used as the content for calling MakeWebPage. If there is no
+// gallery.html, nothing is done.
+func (t Templates) MakeGallery(dir string, g *Gallery) error {
+	tmplt, ok := t["gallery"]
+	if !ok {
+		return nil
+	}
+
+	file := path.Join(dir, g.Url())
+	err := MakeDirIfNotExists(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	// Make the data that will be passed to the templater.
+	data := struct {
+		Helper
+		Gallery *Gallery
+		CDate   string
+	}{
+		Helper:  NewHelper(file),
+		Gallery: g,
+		CDate: pageLocale(file).Format(time.Now().In(Location),
+			pageLocale(file).DateFormat),
+	}
+
+	// Perform the templating
+	content, err := ExecTemplate(tmplt, data)
+	if err != nil {
+		return err
+	}
+
+	// Make the pages with the siteData Helper Function
+	sd := &SiteData{
+		Title:       g.Title,
+		Description: g.Description,
+		Content:     content,
+	}
+	sd.SEO = pageSEO(file, sd)
+	sd.SEO.Image = absoluteUrl(NewHelper(file).Root, g.Cover().Image)
+
+	return t.MakeWebPage(file, sd)
+}
+
+// MakePhoto creates a completed HTML page for the given photo and puts
+// it next to the page of its gallery in the given directory. It uses
+// the template from photo.html and will fill in the following values:
+//
+//      .CDate   - The date the page was created.
+//      .Date    - The date the photo was taken or "".
+//      .Caption - The date, camera and exposure of the photo.
+//      .Gallery - The gallery the photo is in. See MakeGallery.
+//      .Photo   - The photo. It contains:
+//        .Name      - The file name of the photo without its extension.
+//        .Url       - The url of the photo's page.
+//        .Image     - The url of the photo.
+//        .Thumbnail - The small version of the photo. It contains a
+//                     .Url, .Width and .Height.
+//        .Width     - The width of the photo in pixels.
+//        .Height    - The height of the photo in pixels.
+//        .Taken     - When the photo was taken.
+//        .Camera    - The make and model of the camera.
+//        .Exposure  - The exposure time, aperture, ISO and focal
+//                     length.
+//        .Index     - The number of the photo in the gallery starting
+//                     at 1.
+//        .Prev      - The photo before this one or nil.
+//        .Next      - The photo after this one or nil.
+//
+// What's known about each photo comes from its EXIF data. The results
+// of that templating are then used as the content for calling
+// MakeWebPage. If there is no photo.html, nothing is done.
+func (t Templates) MakePhoto(dir string, p *Photo) error {
+	tmplt, ok := t["photo"]
+	if !ok {
+		return nil
+	}
+
+	file := path.Join(dir, p.Url())
+	err := MakeDirIfNotExists(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	l := pageLocale(file)
+	date := ""
+	if !p.Taken.IsZero() {
+		date = l.Format(p.Taken, l.DateFormat)
+	}
+
+	caption := []string{}
+	for _, s := range []string{date, p.Camera, p.Exposure} {
+		if s != "" {
+			caption = append(caption, s)
+		}
+	}
+
+	// Make the data that will be passed to the templater.
+	data := struct {
+		Helper
+		Gallery *Gallery
+		Photo   *Photo
+		Date    string
+		Caption string
+		CDate   string
+	}{
+		Helper:  NewHelper(file),
+		Gallery: p.Gallery,
+		Photo:   p,
+		Date:    date,
+		Caption: strings.Join(caption, " · "),
+		CDate:   l.Format(time.Now().In(Location), l.DateFormat),
+	}
+
+	// Perform the templating
+	content, err := ExecTemplate(tmplt, data)
+	if err != nil {
+		return err
+	}
+
+	// Make the pages with the siteData Helper Function
+	sd := &SiteData{
+		Title:       p.Gallery.Title + " – " + p.Name,
+		Description: data.Caption,
+		Content:     content,
+	}
+	sd.SEO = pageSEO(file, sd)
+	sd.SEO.Image = absoluteUrl(NewHelper(file).Root, p.Image)
+
+	return t.MakeWebPage(file, sd)
+}
+
 // MakeEntry creates a completed HTML page of the given blog entry
 // and puts it in the given directory. It uses the template from
 // entry.html and will fill in the following values:
@@ -275,31 +1039,128 @@ func (t Templates) MakeTags(dir string, ta []*Tag) error {
 //      .UDate   - If the entry has changed since it's original
 //                 creation, this will be the most recent update
 //                 date.
-//      .Content - The HTML formated Content of blog entry.
+//      .Content - The HTML formated Content of blog entry. If the
+//                 entry has a Password, it's encrypted and this is a
+//                 form to decrypt it.
 //      .Tags    - A list of tags (strings) for the blog entry.
+//      .Image    - The url of the featured image relative to the root
+//                 of the site or "". Use {{$.Root}}{{.Image}}.
+//      .ImageAlt - The description of the featured image.
+//      .Thumbnail - The small version of the featured image or nil.
+//                 It contains a .Url, .Width and .Height.
+//      .Terms   - The terms (strings) of the blog entry in each of
+//                 the Taxonomies keyed by the taxonomy.
+//      .WordCount   - The number of words in the entry.
+//      .ReadingTime - The estimated minutes it takes to read it.
+//      .Language - The language of the entry.
+//      .Authors  - The profiles of the people who wrote the entry. See
+//                 MakeAuthor for what each one contains; link to
+//                 their pages with {{$.LanguageRoot}}{{.Url}}.
+//      .Translations - The versions of the entry in other languages.
+//                 Each one is an entry like this one; use
+//                 {{$.Root}}{{.SiteUrl}} to link to it.
+//      .Series   - If the entry is part of a series, its place in it.
+//                 Otherwise, nil. It contains:
+//        .Name  - The name of the series.
+//        .Parts - The entries in the series in order.
+//        .Index - The number of this entry in the series starting
+//                 at 1.
+//        .Prev  - The part before this one or nil.
+//        .Next  - The part after this one or nil.
+//        .Url   - The url of the series page.
+//      .CardUrl  - The url of the entry's social card image. Only
+//                 entries without a featured image have one.
 //
 // The results of that templating are then used as the content for
 // calling MakeWebPage.
 func (t Templates) MakeEntry(dir string, blog *Entry,
 	contents string) error {
 
+	file := path.Join(dir, blog.Url)
+	err := MakeDirIfNotExists(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	// Encrypt the content if it's protected.
+	if blog.Protected() {
+		contents, err = Protect(StripComments(contents), blog.Password,
+			GetLocale(blog.Language))
+		if err != nil {
+			return err
+		}
+	}
+
 	// Get the inner HTML.
-	inner, err := t.makeBlogHelper(blog, contents)
+	inner, err := t.makeBlogHelper(file, blog, contents)
 	if err != nil {
 		return nil
 	}
 
 	// Make the pages with the siteData Helper Function
-	return t.MakeWebPage(path.Join(dir, blog.Url), &SiteData{
+	sd := &SiteData{
 		Title:       blog.Title,
 		Description: blog.Description,
 		Author:      blog.Author,
 		Content:     inner,
 		Languages:   blog.Languages,
+		Language:    blog.Language,
+		Alternates:  entryAlternates(NewHelper(file).Root, blog),
 		AtHome:      false,
 		AtTags:      false,
 		AtArchives:  false,
 		AtAbout:     false,
+	}
+	sd.SEO = entrySEO(file, blog, sd)
+
+	return t.MakeWebPage(file, sd)
+}
+
+// MakeGone creates a completed HTML page in place of the given entry
+// that has expired. It uses the template from gone.html and will fill
+// in the following values:
+//
+//      .CDate - The date the page was created.
+//      .Title - The title of the entry that's gone.
+//
+// If there is no gone.html, the page just says that the entry is no
+// longer available. The results are then used as the content for
+// calling MakeWebPage.
+func (t Templates) MakeGone(dir string, blog *Entry) error {
+	file := path.Join(dir, blog.Url)
+	err := MakeDirIfNotExists(path.Dir(file))
+	if err != nil {
+		return err
+	}
+
+	// Make the data that will be passed to the templater.
+	data := struct {
+		Helper
+		Title string
+		CDate string
+	}{
+		Helper: NewHelper(file),
+		Title:  blog.Title,
+		CDate: pageLocale(file).Format(time.Now().In(Location),
+			pageLocale(file).DateFormat),
+	}
+
+	// Perform the templating
+	content := "<p>" + template.HTMLEscapeString(
+		pageLocale(file).T("This page is no longer available.")) + "</p>"
+	if tmplt, ok := t["gone"]; ok {
+		content, err = ExecTemplate(tmplt, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Make the pages with the siteData Helper Function
+	return t.MakeWebPage(file, &SiteData{
+		Title:      blog.Title,
+		Content:    content,
+		Language:   blog.Language,
+		Alternates: []*Alternate{},
 	})
 }
 
@@ -313,44 +1174,97 @@ func (t Templates) MakeEntry(dir string, blog *Entry,
 //      .Author      - The author of this page.
 //      .Content     - The pages content.
 //      .Languages   - A list of languages (string) used by the page.
+//      .Language    - The language of the page if the site is
+//                     multilingual.
+//      .Alternates  - The versions of this page in other languages for
+//                     <link rel="alternate" hreflang="...">. Each one
+//                     contains:
+//        .Language - The language of the page.
+//        .Url      - The url of the page.
+//      .SEO         - The metadata of the page for search engines and
+//                     social networks. {{.SEO.HTML}} has all of it
+//                     ready for the <head>. It also contains:
+//        .Canonical   - The url of the page.
+//        .Title       - The title of the page.
+//        .Description - The description of the page.
+//        .Type        - "article" for entries, otherwise "website".
+//        .SiteName    - The name of the site from channel.rss.
+//        .Image       - The url of the image for previews or "".
+//        .Authors     - The names of the authors.
+//        .Published   - When the page was created.
+//        .Modified    - When the page was last updated.
+//        .Tags        - The tags of the page.
+//        .OpenGraph   - The og:* <meta> tags. Each has a Name and
+//                       Content.
+//        .Twitter     - The twitter:* <meta> tags.
+//        .JSONLD      - The JSON-LD object of the page.
+//      .Root        - The relative path to the root of the site.
+//      .Data        - The contents of the data files. Like .Root, it's
+//                     available in every template. See LoadData.
+//      .LanguageRoot - The relative path to the root of the site in
+//                     the page's language.
 //      .AtHome      - If true, the page is the index.html page.
 //      .AtTags      - If true, the page is the index.html page.
 //      .AtArchives  - If true, the page is the index.html page.
 //      .AtAbout     - If true, the page is the index.html page.
 func (t Templates) MakeWebPage(file string, sd *SiteData) error {
-	// Get a file handle to write the contents to.
-	f, err := os.Create(file)
+	// Fill in the values that depend on where the page is.
+	sd.Helper = NewHelper(file)
+	if sd.Language == "" {
+		sd.Language = pageLanguage(file)
+	}
+	if sd.Alternates == nil {
+		sd.Alternates = pageAlternates(file)
+	}
+	if sd.SEO == nil {
+		sd.SEO = pageSEO(file, sd)
+	}
+
+	// Perform the templating.
+	buf := new(bytes.Buffer)
+	err := t["site"].Execute(buf, sd)
 	if err != nil {
 		return err
 	}
-	defer f.Close()
 
-	err = t["site"].Execute(f, sd)
+	// Minify it if requested.
+	page, err := MinifyHTML(buf.Bytes())
 	if err != nil {
 		return err
 	}
 
-	return nil
+	return ioutil.WriteFile(file, page, 0644)
 }
 
 // makeBLogHelper is a helper function that generates the main content
 // of a blog entry from the entry.html template.
-func (t Templates) makeBlogHelper(blog *Entry,
+func (t Templates) makeBlogHelper(file string, blog *Entry,
 	contents string) (string, error) {
 
+	// The translations that have expired are gone.
+	translations := []*Entry{}
+	for _, tr := range blog.Translations {
+		if !tr.Expired() {
+			translations = append(translations, tr)
+		}
+	}
+
 	// Make the data that will be passed to the templater.
 	templateData := struct {
 		Helper
 		*Entry
-		Content string
+		Content      string
+		Translations []*Entry
 	}{
-		false,
+		NewHelper(file),
 		blog,
 		contents,
+		translations,
 	}
 
 	// Perform the templating
-	return ExecTemplate(t["entry"], templateData)
+	return ExecTemplate(t[GetSection(blog.Section).EntryTemplate],
+		templateData)
 }
 
 // LoadTemplates reads templates from the given directory and returns
@@ -372,7 +1286,13 @@ func (t Templates) makeBlogHelper(blog *Entry,
 //                   creation, this will be the most recent update
 //                   date.
 //        .Content - The HTML formated Content of blog entry.
+//        .Summary - The HTML formated summary of the blog entry.
+//        .Truncated - If true, the summary isn't the whole entry.
+//        .WordCount   - The number of words in the entry.
+//        .ReadingTime - The estimated minutes it takes to read it.
 //        .Tags    - A list of tags (strings) for the blog entry.
+//        .Image   - The url of the featured image or "".
+//        .Thumbnail - The small version of the featured image or nil.
 //  entry.html - Display a single entry.
 //    Variables:
 //  site.html - The sites main template. All pages derive from this
@@ -381,6 +1301,33 @@ func (t Templates) makeBlogHelper(blog *Entry,
 //  tags.html - The sites list of tags.
 //    Variables:
 //
+// The following templates are optional. Unless noted, the pages that
+// use them are only generated if they exist:
+//
+//  author.html - The page for each author. See MakeAuthor.
+//  series.html - The page for each series. See MakeSeries.
+//  archive-year.html - The page for each year. Without it,
+//                      archive.html is used. See MakeArchiveYear.
+//  archive-month.html - The page for each month. Without it,
+//                       archive.html is used. See MakeArchiveMonth.
+//  tags-term.html - The page for each tag. See MakeTerm.
+//  updated.html - The recently updated entries. See MakeUpdated.
+//  gone.html - The page that replaces an expired entry. See MakeGone.
+//  galleries.html - The list of photo galleries. See MakeGalleries.
+//  gallery.html - The page for each photo gallery. See MakeGallery.
+//  photo.html - The page for each photo in a gallery. See MakePhoto.
+//
+// Each of the Taxonomies also has an optional template named after it
+// that lists its terms (see MakeTaxonomy) and one followed by -term
+// for the page of each term (see MakeTerm).
+//
+// Every template can use the asset function to get the url of the
+// fingerprinted copy of a static asset (e.g. {{asset "css/site.css"}}),
+// the T function to translate a string into the language of the page
+// (e.g. {{T "Read more"}}) and the sortBy function to sort a list of
+// entries (e.g. {{range sortBy "title" .Entries}}). The markdown
+// function converts markdown to HTML.
+//
 // All of the templates must exist for this to succeed.
 func LoadTemplates(dir string) (Templates, error) {
 	// This will be our return value.
@@ -396,18 +1343,64 @@ func LoadTemplates(dir string) (Templates, error) {
 		"tags",
 	}
 
-	// Process each template.
+	// The sections may use templates of their own. They can share
+	// them with each other and with the site, but each one is only
+	// loaded once.
+	seen := map[string]bool{}
 	for _, t := range templates {
+		seen[t] = true
+	}
+	for _, s := range Sections {
+		for _, t := range s.templates() {
+			if !seen[t] {
+				seen[t] = true
+				templates = append(templates, t)
+			}
+		}
+	}
+
+	// These templates are only used if they exist.
+	optional := []string{
+		"author",
+		"series",
+		"archive-year",
+		"archive-month",
+		"tags-term",
+		"updated",
+		"gone",
+		"galleries",
+		"gallery",
+		"photo",
+	}
+	for _, taxonomy := range Taxonomies {
+		optional = append(optional, taxonomy, taxonomy+"-term")
+	}
+
+	// A section may use one of them too, but then it has to exist.
+	required := len(templates)
+	for _, t := range optional {
+		if !seen[t] {
+			seen[t] = true
+			templates = append(templates, t)
+		}
+	}
+
+	// Process each template.
+	for i, t := range templates {
 		filename := path.Join(dir, t+".html")
 
 		// Get the contents.
 		contents, err := ioutil.ReadFile(filename)
 		if err != nil {
+			if i >= required && os.IsNotExist(err) {
+				continue
+			}
 			return nil, err
 		}
 
 		// Generate the template.
-		tmplt, err := template.New(t).Parse(string(contents))
+		tmplt, err := template.New(t).Funcs(templateFuncs).
+			Parse(string(contents))
 		if err != nil {
 			return nil, err
 		}